@@ -0,0 +1,99 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// agentSettings is written by the check-agent-settings errand.
+type agentSettings struct {
+	AgentID string `json:"agent_id"`
+	Env     struct {
+		PasswordSHA256   string `json:"password_sha256"`
+		KeepRootPassword bool   `json:"keep_root_password"`
+		RemoveDevTools   bool   `json:"remove_dev_tools"`
+	} `json:"env"`
+	BlobstoreProvider string `json:"blobstore_provider"`
+	RootCertificates  []struct {
+		Thumbprint string `json:"thumbprint"`
+		Subject    string `json:"subject"`
+	} `json:"root_certificates"`
+}
+
+// certThumbprint is the thumbprint Windows shows for a PEM encoded certificate.
+func certThumbprint(certPEM string) string {
+	block, _ := pem.Decode([]byte(certPEM))
+	Expect(block).NotTo(BeNil(), "trusted_cert is not a PEM encoded certificate")
+
+	sum := sha1.Sum(block.Bytes)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+var _ = Describe("Agent settings", Ordered, func() {
+	var (
+		settingsDeploymentName string
+		password               string
+		settings               agentSettings
+	)
+
+	BeforeAll(func() {
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		settingsDeploymentName = fmt.Sprintf("windows-acceptance-test-agent-settings-%d", getTimestampInMs())
+		password = fmt.Sprintf("bwats-%d", getTimestampInMs())
+		err = testConfig.deployWithManifestAndVars(boshCommand, settingsDeploymentName, stemcellVersion, releaseVersion,
+			filepath.Join(pwd, "assets", "agent-settings-manifest.yml"),
+			map[string]interface{}{
+				"AgentPassword":    password,
+				"KeepRootPassword": true,
+				"RemoveDevTools":   true,
+			})
+		Expect(err).NotTo(HaveOccurred())
+
+		body := runErrandAndReadLog(settingsDeploymentName, "check-agent-settings", "check-agent-settings/settings.json", boshCommand)
+		Expect(json.Unmarshal(body, &settings)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		if testConfig.SkipCleanup {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", settingsDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("applies the agent env from the manifest", func() {
+		passwordSHA256 := sha256.Sum256([]byte(password))
+		Expect(settings.Env.PasswordSHA256).To(Equal(hex.EncodeToString(passwordSHA256[:])))
+		Expect(settings.Env.KeepRootPassword).To(BeTrue())
+		Expect(settings.Env.RemoveDevTools).To(BeTrue())
+
+		instances := boshCommand.RunTable(fmt.Sprintf("-d %s instances --details", settingsDeploymentName))
+		Expect(instances).To(HaveLen(1))
+		Expect(settings.AgentID).To(Equal(instances[0]["agent_id"]))
+		Expect(settings.BlobstoreProvider).NotTo(BeEmpty())
+	})
+
+	It("adds the director's trusted certificates to LocalMachine\\Root", func() {
+		if testConfig.TrustedCert == "" {
+			Skip("Skipping trusted certificates - trusted_cert not set. The suite does not configure the director: " +
+				"add the CA to its trusted_certs first, e.g. with assets/director-trusted-certs.yml when running bosh create-env")
+		}
+
+		thumbprint := certThumbprint(testConfig.TrustedCert)
+		Expect(settings.RootCertificates).To(ContainElement(HaveField("Thumbprint", thumbprint)),
+			fmt.Sprintf("%s is not in LocalMachine\\Root", thumbprint))
+	})
+})
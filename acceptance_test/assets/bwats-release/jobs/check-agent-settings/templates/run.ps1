@@ -0,0 +1,36 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+$LogDir = "C:\var\vcap\sys\log\check-agent-settings"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+function Get-Sha256 {
+  param([string]$Value)
+
+  $sha256 = [System.Security.Cryptography.SHA256]::Create()
+  $hash = $sha256.ComputeHash([System.Text.Encoding]::UTF8.GetBytes($Value))
+  return ([System.BitConverter]::ToString($hash) -replace "-", "").ToLower()
+}
+
+$Settings = Get-Content "C:\var\vcap\bosh\settings.json" -Raw | ConvertFrom-Json
+$BoshEnv = $Settings.env.bosh
+
+# Only a digest of the password is reported, and only the provider of the
+# blobstore, whose options hold credentials.
+$Report = @{
+  agent_id = $Settings.agent_id
+  env = @{
+    password_sha256 = if ($BoshEnv.password) { Get-Sha256 $BoshEnv.password } else { "" }
+    keep_root_password = [bool]$BoshEnv.keep_root_password
+    remove_dev_tools = [bool]$BoshEnv.remove_dev_tools
+  }
+  blobstore_provider = "$($Settings.blobstore.provider)"
+  root_certificates = @(Get-ChildItem Cert:\LocalMachine\Root | ForEach-Object {
+    @{ thumbprint = $_.Thumbprint; subject = $_.Subject }
+  })
+}
+
+Write-Host "Agent $($Report.agent_id) uses the $($Report.blobstore_provider) blobstore"
+[System.IO.File]::WriteAllText((Join-Path $LogDir "settings.json"), ($Report | ConvertTo-Json -Depth 4 -Compress))
+
+Exit 0
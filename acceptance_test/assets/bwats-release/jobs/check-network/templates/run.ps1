@@ -0,0 +1,56 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+$LogDir = "C:\var\vcap\sys\log\check-network"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+$Interfaces = @(Get-NetIPConfiguration | ForEach-Object {
+  @{
+    alias = $_.InterfaceAlias
+    index = $_.InterfaceIndex
+    ips = @($_.IPv4Address | ForEach-Object { $_.IPAddress })
+    dns_servers = @($_.DNSServer | Where-Object { $_.AddressFamily -eq 2 } | ForEach-Object { $_.ServerAddresses })
+  }
+})
+
+# The effective metric of a route is the sum of the route and interface metrics.
+$Routes = @(Get-NetRoute -AddressFamily IPv4 -DestinationPrefix "0.0.0.0/0" | ForEach-Object {
+  $interfaceMetric = (Get-NetIPInterface -AddressFamily IPv4 -InterfaceIndex $_.InterfaceIndex).InterfaceMetric
+  @{
+    destination = $_.DestinationPrefix
+    next_hop = $_.NextHop
+    interface_index = $_.InterfaceIndex
+    metric = $_.RouteMetric + $interfaceMetric
+  }
+})
+
+$MetadataServerRules = @(Get-NetFirewallRule | Where-Object {
+  ($_ | Get-NetFirewallAddressFilter).RemoteAddress -contains "169.254.169.254"
+} | ForEach-Object {
+  @{
+    name = $_.Name
+    enabled = "$($_.Enabled)" -eq "True"
+    direction = "$($_.Direction)"
+    action = "$($_.Action)"
+  }
+})
+
+# The networks the agent was asked to configure, by name.
+$Settings = Get-Content "C:\var\vcap\bosh\settings.json" -Raw | ConvertFrom-Json
+$Networks = @{}
+foreach ($network in $Settings.networks.PSObject.Properties) {
+  $Networks[$network.Name] = @{
+    ip = "$($network.Value.ip)"
+    default = @($network.Value.default | Where-Object { $_ })
+  }
+}
+
+$Report = @{
+  networks = $Networks
+  interfaces = $Interfaces
+  default_routes = $Routes
+  metadata_server_rules = $MetadataServerRules
+}
+[System.IO.File]::WriteAllText((Join-Path $LogDir "network.json"), ($Report | ConvertTo-Json -Depth 4 -Compress))
+
+Exit 0
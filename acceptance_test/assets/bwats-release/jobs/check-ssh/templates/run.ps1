@@ -1,25 +1,35 @@
 $ErrorActionPreference = "Stop"
 trap { $host.SetShouldExit(1) }
 
+# bosh ssh creates a bosh_* user for each session, and removes it, along with
+# its profile, when the session ends. Only the registry hives of a profile may
+# be left behind while they are loaded.
+$LogDir = "C:\var\vcap\sys\log\check-ssh"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+$boshUsers = @((NET.EXE USER) -Split '\s+' | Where {$_ -Like 'bosh_*'})
+
+$unexpectedFiles = @(Get-Childitem C:\Users\bosh_* | `
+    foreach { Get-ChildItem -Force -Recurse -Attributes !Directory -Exclude 'ntuser.dat*', 'usrclass.dat*' $_ } | `
+    foreach { $_.FullName })
+
+$ReportPath = Join-Path $LogDir "ssh-users.json"
+$Report = @{
+  users = $boshUsers
+  files = $unexpectedFiles
+}
+# WriteAllText writes UTF-8 without a byte order mark
+[System.IO.File]::WriteAllText($ReportPath, (ConvertTo-Json -InputObject $Report -Depth 2 -Compress))
+
 $success = $True
 
-$boshUsers = (NET.EXE USER) -Split '\s+' | Where {$_ -Like 'bosh_*'}
-if ($boshUsers -ne $null) {
-    if ($boshUsers -Is [System.String]) {
-        # Singular, alternative is a collection
-        Write-Error "Expected not to find any bosh users, however the user $boshUsers exists"
-    } else {
-        # Plural
-        Write-Error "Expected not to find any bosh users, however the following users exist: $($boshUsers -Join ', ')"
-    }
+if ($boshUsers.Count -ne 0) {
+    Write-Host "Expected not to find any bosh users, however the following users exist: $($boshUsers -Join ', ')"
     $success = $False
 }
 
-$unexpectedFiles = Get-Childitem C:\Users\bosh_* | `
-    foreach { Get-ChildItem -Force -Recurse -Attributes !Directory -Exclude 'ntuser.dat*', 'usrclass.dat*' $_ }
-
-if ($unexpectedFiles -ne $null) {
-    Write-Error "Expect to only find certain registry files associated with bosh users on file system, instead found $(($unexpectedFiles | Measure-Object).Count) other file(s)"
+if ($unexpectedFiles.Count -ne 0) {
+    Write-Host "Expect to only find certain registry files associated with bosh users on file system, instead found $($unexpectedFiles.Count) other file(s)"
     $success = $False
 }
 
@@ -1,25 +1,91 @@
 $ErrorActionPreference = "Stop"
 trap { $host.SetShouldExit(1) }
 
+function Get-Config {
+  $configPath = Join-Path $PSScriptRoot "config.json"
+  Write-Host "Loading '$configPath'"
+  $config = Get-Content $configPath -raw | ConvertFrom-Json
+  Write-Host "Loaded '$configPath'"
+  return $config
+}
+
+$config = Get-Config
+
+$LogDir = "C:\var\vcap\sys\log\check-updates"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+function Test-Excluded {
+  param($Update, [string[]]$KBs)
+
+  foreach ($Exclusion in @($config.exclude)) {
+    if ($KBs -contains $Exclusion -or $Update.Title -like "*$Exclusion*") {
+      return $true
+    }
+  }
+  return $false
+}
+
 Set-Service -Name wuauserv -StartupType Manual
 Start-Service -Name wuauserv
 
 $Session = New-Object -ComObject Microsoft.Update.Session
-Write-Host "Session: $Session"
 $Searcher = $Session.CreateUpdateSearcher()
-Write-Host "Searcher: $Searcher"
-$UninstalledUpdates = $Searcher.Search("IsInstalled=0 and Type='Software' and IsHidden=0").Updates
-$FilteredUpdates = @($UninstalledUpdates | `
-    Where-Object {$_.Title -notmatch "KB2267602" -And $_.Title -NotMatch "KB4052623"})
-if ($FilteredUpdates.Count -ne 0) {
-    Write-Log "The following updates are not currently installed:"
-    foreach ($Update in $FilteredUpdates) {
-        Write-Log "> $($Update.Title)"
-    }
-    Write-Error 'There are uninstalled updates'
-    Exit 1
+
+$ServiceManager = $null
+$Service = $null
+if ($config.offline_catalog) {
+  $Catalog = "C:\var\vcap\packages\wsus-offline-catalog\wsusscn2.cab"
+  if ((Get-Item $Catalog).Length -eq 0) {
+    Write-Error "check_updates.offline_catalog is set, but the release has no offline catalog"
+  }
+
+  Write-Host "Searching the offline catalog $Catalog"
+  $ServiceManager = New-Object -ComObject Microsoft.Update.ServiceManager
+  $Service = $ServiceManager.AddScanPackageService("bwats offline catalog", $Catalog, 1)
+  # ssOthers: search the service given by ServiceID
+  $Searcher.ServerSelection = 3
+  $Searcher.ServiceID = $Service.ServiceID
 } else {
-  Write-Host "No pending updates found by UpdateSearcher"
+  Write-Host "Searching Microsoft Update"
+}
+
+try {
+  $Updates = $Searcher.Search("IsInstalled=0 and Type='Software' and IsHidden=0").Updates
+} finally {
+  if ($Service -ne $null) {
+    $ServiceManager.RemoveService($Service.ServiceID)
+  }
+}
+
+$Missing = @()
+foreach ($Update in $Updates) {
+  $KBs = @($Update.KBArticleIDs | ForEach-Object { "KB$_" })
+  if (Test-Excluded -Update $Update -KBs $KBs) {
+    Write-Host "Excluded: $($Update.Title)"
+    continue
+  }
+
+  $Classification = $Update.Categories | Where-Object { $_.Type -eq "UpdateClassification" } | Select-Object -First 1
+  $Missing += [ordered]@{
+    kb = ($KBs -join ",")
+    title = $Update.Title
+    classification = "$($Classification.Name)"
+    severity = "$($Update.MsrcSeverity)"
+  }
+}
+
+$Report = ConvertTo-Json -Depth 3 -InputObject ([ordered]@{
+  offline_catalog = [bool]$config.offline_catalog
+  missing = $Missing
+})
+[System.IO.File]::WriteAllText("$LogDir\updates.json", $Report)
+
+if ($Missing.Count -ne 0) {
+  Write-Host "The following updates are not currently installed:"
+  foreach ($Update in $Missing) {
+    Write-Host "> $($Update.kb) $($Update.title)"
+  }
+  Write-Error "There are $($Missing.Count) uninstalled updates"
 }
 
-Write-Host "Finished checking updates."
+Write-Host "No pending updates found by UpdateSearcher"
@@ -1,49 +1,32 @@
 $ErrorActionPreference = "Stop"
 trap { $host.SetShouldExit(1) }
 
-function NonTerminatingError() {
-  Param(
-    [Parameter(Mandatory=$True)]
-    [string]$Message
-  )
-
-  $ErrorActionPreference = "Continue"
-  Write-Error $Message
-  $ErrorActionPreference = "Stop"
-}
-
-certutil -generateSSTFromWU wucerts.sst
-
-$currentCert = ""
-$expectedCerts = @()
-certutil -dump wucerts.sst | ForEach-Object {
-    if ($_ -match "==== Certificate") {
-        if ($currentCert -ne "") {
-            $expectedCerts += $currentCert
-        }
-        $currentCert = $_
-    } elseif ($_ -match "CertUtil: -dump command completed") {
-        $expectedCerts += $currentCert
-   } else {
-        $currentCert += [Environment]::NewLine
-        $currentCert += $_
-   }
-}
-
-$systemCertThumbprints = Get-ChildItem Cert:/ -Recurse | ForEach-Object {
-    $_.Thumbprint
+# The certificates of the Windows Update server and those of the system stores
+# are written to the errand's log directory, and compared by the acceptance
+# test, which parses the SST itself.
+$LogDir = "C:\var\vcap\sys\log\check-wu-certs"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+$SSTPath = Join-Path $LogDir "wucerts.sst"
+Remove-Item -Force -ErrorAction SilentlyContinue $SSTPath
+certutil -generateSSTFromWU $SSTPath
+if ($LASTEXITCODE -ne 0) {
+  Write-Error "certutil -generateSSTFromWU failed with exit code $LASTEXITCODE"
 }
 
-$failed = $False
-$expectedCerts | ForEach-Object {
-    $expectedCertThumbprint = ($_ | findstr /R /C:"Cert Hash(sha1)").split(" ")[2]
-
-    if ($systemCertThumbprints -notcontains $expectedCertThumbprint) {
-        $failed = $True
-        NonTerminatingError "Expected to find the following certificate in the system certificates, but didn't: $_"
-    }
-}
-
-if ($failed) {
-    Write-Error "Some certificates found on the Windows Update server were not present on the host"
-}
+$SystemCerts = @(Get-ChildItem Cert:\ -Recurse | Where-Object { -not $_.PSIsContainer } | ForEach-Object {
+  @{
+    # e.g. LocalMachine\Root
+    store = ($_.PSParentPath -split "::")[-1]
+    thumbprint = $_.Thumbprint
+    subject = $_.Subject
+    not_after = $_.NotAfter.ToUniversalTime().ToString("o")
+  }
+})
+
+$SystemCertsPath = Join-Path $LogDir "system-certs.json"
+# WriteAllText writes UTF-8 without a byte order mark
+[System.IO.File]::WriteAllText($SystemCertsPath, (ConvertTo-Json -InputObject $SystemCerts -Depth 2 -Compress))
+
+Write-Host "Wrote $SSTPath and the $($SystemCerts.Count) certificates of the system stores to $SystemCertsPath"
+Exit 0
@@ -0,0 +1,23 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+$LogDir = "C:\var\vcap\sys\log\container-smoke"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+if ((Get-WindowsFeature -Name Containers).InstallState -ne "Installed") {
+  Write-Error "The Containers feature is not installed"
+}
+
+# The base layer is the only directory in the extracted blob.
+$BaseLayer = Get-ChildItem -Directory "C:\var\vcap\packages\container-base-layer\layer" | Select-Object -First 1
+if ($BaseLayer -eq $null) {
+  Write-Error "The container-base-layer package does not contain a base layer"
+}
+
+& "C:\var\vcap\packages\container-smoke\container-smoke.exe" `
+  -base-layer $BaseLayer.FullName `
+  -work-dir "C:\var\vcap\data\container-smoke" `
+  -report "$LogDir\container.json"
+if ($LASTEXITCODE -ne 0) {
+  Write-Error "container-smoke failed with exit code $LASTEXITCODE"
+}
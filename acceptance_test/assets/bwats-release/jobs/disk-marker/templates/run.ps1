@@ -0,0 +1,49 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+function Get-Config {
+  $configPath = Join-Path $PSScriptRoot "config.json"
+  Write-Host "Loading '$configPath'"
+  $config = Get-Content $configPath -raw | ConvertFrom-Json
+  Write-Host "Loaded '$configPath'"
+  return $config
+}
+
+$config = Get-Config
+
+$LogDir = "C:\var\vcap\sys\log\disk-marker"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+if (-Not (Test-Path "C:\var\vcap\store")) {
+  Write-Error "Persistent disk is not mounted at C:\var\vcap\store"
+  Exit 1
+}
+
+$Markers = @{
+  persistent = "C:\var\vcap\store\disk-marker\marker.txt"
+  ephemeral = "C:\var\vcap\data\disk-marker\marker.txt"
+}
+
+# The markers are reported before new ones are written, so that a run after a
+# redeploy shows which disks kept the markers of the previous run.
+$StemcellVersionFile = "C:\var\vcap\bosh\etc\stemcell_version"
+$Report = @{
+  stemcell_version = if (Test-Path $StemcellVersionFile) { (Get-Content $StemcellVersionFile -Raw).Trim() } else { "" }
+}
+foreach ($disk in $Markers.Keys) {
+  $path = $Markers[$disk]
+  $Report[$disk] = if (Test-Path $path) { (Get-Content $path -Raw).Trim() } else { "" }
+  Write-Host "Found $disk marker: '$($Report[$disk])'"
+}
+
+[System.IO.File]::WriteAllText((Join-Path $LogDir "markers.json"), ($Report | ConvertTo-Json -Compress))
+
+if ($config.marker_value -ne "") {
+  foreach ($path in $Markers.Values) {
+    New-Item -ItemType Directory -Force -Path (Split-Path $path) | Out-Null
+    Set-Content -Path $path -Value $config.marker_value
+  }
+  Write-Host "Wrote markers: '$($config.marker_value)'"
+}
+
+Exit 0
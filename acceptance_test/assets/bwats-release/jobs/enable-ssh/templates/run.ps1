@@ -0,0 +1,30 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+$OpenSSHDir = "C:\Program Files\OpenSSH"
+
+# A stemcell with ssh disabled by default may not have generated host keys.
+if (-not (Test-Path "$env:ProgramData\ssh\ssh_host_*_key")) {
+  Push-Location $OpenSSHDir
+  try {
+    .\ssh-keygen.exe -A
+    if ($LASTEXITCODE -ne 0) {
+      throw "ssh-keygen -A failed with exit code $LASTEXITCODE"
+    }
+  } finally {
+    Pop-Location
+  }
+}
+
+if (-not (Get-NetFirewallRule -Name "OpenSSH-Server-In-TCP" -ErrorAction SilentlyContinue)) {
+  New-NetFirewallRule -Name "OpenSSH-Server-In-TCP" -DisplayName "OpenSSH Server (sshd)" `
+    -Enabled True -Direction Inbound -Protocol TCP -Action Allow -LocalPort 22 | Out-Null
+}
+
+foreach ($service in "sshd", "ssh-agent") {
+  Set-Service -Name $service -StartupType Automatic
+  Start-Service -Name $service
+  Write-Host "$service is $((Get-Service -Name $service).Status)"
+}
+
+Exit 0
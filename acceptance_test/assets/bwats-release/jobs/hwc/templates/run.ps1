@@ -0,0 +1,22 @@
+$ErrorActionPreference = "Stop"
+
+# HWCServer serves C:\, so the app is copied to C:\bwats-hwc and is available at
+# http://localhost:<port>/bwats-hwc/Default.aspx.
+$AppDir = "C:\bwats-hwc"
+New-Item -ItemType Directory -Force -Path $AppDir | Out-Null
+Copy-Item -Force (Join-Path $PSScriptRoot "..\app\Default.aspx") $AppDir
+
+# HWCServer shuts down when it reads a line from stdin, so it is given a stdin
+# that is never written to rather than the one of the job.
+$startInfo = New-Object System.Diagnostics.ProcessStartInfo
+$startInfo.FileName = "C:\var\vcap\packages\hwc-server\HWCServer.exe"
+$startInfo.Arguments = "<%= p("hwc.port") %>"
+$startInfo.UseShellExecute = $False
+$startInfo.RedirectStandardInput = $True
+
+Write-Host "Starting HWCServer on port <%= p("hwc.port") %>"
+$process = [System.Diagnostics.Process]::Start($startInfo)
+$process.WaitForExit()
+
+Write-Host "HWCServer exited with $($process.ExitCode)"
+Exit 1
@@ -0,0 +1,14 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+# Each line of updates.log is "<generation> <start unix ms> <end unix ms>".
+$UpdatesLog = "C:\var\vcap\data\identity\updates.log"
+New-Item -ItemType Directory -Force -Path (Split-Path $UpdatesLog) | Out-Null
+
+$start = [DateTimeOffset]::UtcNow.ToUnixTimeMilliseconds()
+Start-Sleep -Seconds <%= p("identity.pre_start_seconds") %>
+$end = [DateTimeOffset]::UtcNow.ToUnixTimeMilliseconds()
+
+Add-Content -Path $UpdatesLog -Value "<%= p("identity.generation") %> $start $end"
+Write-Host "Updated to generation <%= p("identity.generation") %>"
+Exit 0
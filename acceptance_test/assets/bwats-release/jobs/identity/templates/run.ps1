@@ -0,0 +1,38 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+function Get-Config {
+  $configPath = Join-Path $PSScriptRoot "config.json"
+  Write-Host "Loading '$configPath'"
+  $config = Get-Content $configPath -raw | ConvertFrom-Json
+  Write-Host "Loaded '$configPath'"
+  return $config
+}
+
+$config = Get-Config
+
+$LogDir = "C:\var\vcap\sys\log\identity"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+# The default user is the built-in administrator, whatever it is named.
+$User = Get-LocalUser | Where-Object { $_.SID.Value -like "S-1-5-21-*-500" }
+
+$Updates = @()
+$UpdatesLog = "C:\var\vcap\data\identity\updates.log"
+if (Test-Path $UpdatesLog) {
+  $Updates = @(Get-Content $UpdatesLog | Where-Object { $_.Trim() -ne "" } | ForEach-Object {
+    $generation, $start, $end = $_ -split " "
+    @{ generation = [int]$generation; start = [long]$start; end = [long]$end }
+  })
+}
+
+$Report = @{
+  index = $config.index
+  hostname = $env:COMPUTERNAME
+  password_last_set = $User.PasswordLastSet.ToUniversalTime().ToString("o")
+  updates = $Updates
+}
+
+[System.IO.File]::WriteAllText((Join-Path $LogDir "identity.json"), ($Report | ConvertTo-Json -Depth 3 -Compress))
+
+Exit 0
@@ -0,0 +1,89 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+# The inventory is written to the errand's log directory so that it is
+# downloaded with `bosh run-errand --download-logs`.
+$LogDir = "C:\var\vcap\sys\log\inventory"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+$StemcellVersionFile = "C:\var\vcap\bosh\etc\stemcell_version"
+$StemcellVersion = if (Test-Path $StemcellVersionFile) { (Get-Content $StemcellVersionFile -Raw).Trim() } else { "" }
+
+$Features = @(Get-WindowsFeature | Where-Object { $_.Installed } | ForEach-Object { $_.Name })
+
+$Hotfixes = @(Get-HotFix | ForEach-Object {
+  @{ id = $_.HotFixID; description = $_.Description }
+})
+
+$Services = @(Get-Service | ForEach-Object {
+  @{ name = $_.Name; start_type = $_.StartType.ToString(); status = $_.Status.ToString() }
+})
+
+$Users = @(Get-LocalUser | ForEach-Object {
+  @{ name = $_.Name; enabled = $_.Enabled }
+})
+
+$Certificates = @(Get-ChildItem Cert:\LocalMachine -Recurse | Where-Object { -not $_.PSIsContainer } | ForEach-Object {
+  @{
+    store = (Split-Path $_.PSParentPath -Leaf)
+    thumbprint = $_.Thumbprint
+    subject = $_.Subject
+    not_after = $_.NotAfter.ToUniversalTime().ToString("o")
+  }
+})
+
+$FirewallRules = @(Get-NetFirewallRule | ForEach-Object {
+  @{
+    name = $_.Name
+    display_name = $_.DisplayName
+    enabled = $_.Enabled.ToString()
+    direction = $_.Direction.ToString()
+    action = $_.Action.ToString()
+  }
+})
+
+$ScheduledTasks = @(Get-ScheduledTask | ForEach-Object {
+  @{ path = $_.TaskPath; name = $_.TaskName; state = $_.State.ToString() }
+})
+
+# The same program is often installed for both architectures under the same
+# name, e.g. the Visual C++ redistributables, so each is recorded with the
+# registry view it is listed in.
+$UninstallKeys = @{
+  x64 = "HKLM:\Software\Microsoft\Windows\CurrentVersion\Uninstall\*"
+  x86 = "HKLM:\Software\Wow6432Node\Microsoft\Windows\CurrentVersion\Uninstall\*"
+}
+$Programs = @(foreach ($Architecture in $UninstallKeys.Keys) {
+  Get-ItemProperty $UninstallKeys[$Architecture] -ErrorAction SilentlyContinue | Where-Object { $_.DisplayName } | ForEach-Object {
+    @{ name = $_.DisplayName; architecture = $Architecture; version = "$($_.DisplayVersion)"; publisher = "$($_.Publisher)" }
+  }
+})
+
+$BoshBinaries = @(Get-ChildItem "C:\var\vcap\bosh\bin" -File | ForEach-Object {
+  @{
+    name = $_.Name
+    version = "$($_.VersionInfo.FileVersion)"
+    sha256 = (Get-FileHash $_.FullName -Algorithm SHA256).Hash.ToLower()
+  }
+})
+
+$Inventory = @{
+  stemcell_version = $StemcellVersion
+  os_version = [System.Environment]::OSVersion.Version.ToString()
+  features = $Features
+  hotfixes = $Hotfixes
+  services = $Services
+  users = $Users
+  certificates = $Certificates
+  firewall_rules = $FirewallRules
+  scheduled_tasks = $ScheduledTasks
+  programs = $Programs
+  bosh_binaries = $BoshBinaries
+}
+
+$InventoryPath = Join-Path $LogDir "inventory.json"
+# WriteAllText writes UTF-8 without a byte order mark
+[System.IO.File]::WriteAllText($InventoryPath, ($Inventory | ConvertTo-Json -Depth 4 -Compress))
+
+Write-Host "Wrote inventory of stemcell $StemcellVersion to $InventoryPath"
+Exit 0
@@ -0,0 +1,21 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+. (Join-Path $PSScriptRoot "hooks.ps1")
+
+# The first call asks the agent to wait by printing a negative number of
+# seconds. The agent then calls the script again with job_check_status, which
+# finishes draining.
+$WaitFile = Join-Path $DataDir "drain-waited"
+
+if (-Not (Test-Path $WaitFile)) {
+  Invoke-Hook "drain-wait" {
+    New-Item -ItemType File -Force -Path $WaitFile | Out-Null
+    Write-Output "-<%= p("lifecycle.drain_wait_seconds") %>"
+  }
+}
+
+Invoke-Hook "drain" {
+  Remove-Item $WaitFile
+  Write-Output "0"
+}
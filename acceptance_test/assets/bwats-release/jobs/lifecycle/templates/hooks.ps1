@@ -0,0 +1,47 @@
+# Each line of hooks.log is "<unix time in ms> <event> <exit code>". The log is
+# kept on the persistent disk, so that it survives recreating the VM, and copied
+# to C:\var\vcap\sys\log\lifecycle for bosh logs. Nothing is written to stdout,
+# which the agent reads from the drain script.
+$HooksLog = "C:\var\vcap\store\lifecycle\hooks.log"
+$LogDir = "C:\var\vcap\sys\log\lifecycle"
+$DataDir = "C:\var\vcap\data\lifecycle"
+$PidFile = Join-Path $DataDir "run.pid"
+
+function Write-HookEvent {
+  param([string]$Name, [int]$ExitCode)
+
+  New-Item -ItemType Directory -Force -Path (Split-Path $HooksLog) | Out-Null
+  New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+  $timestamp = [DateTimeOffset]::UtcNow.ToUnixTimeMilliseconds()
+  Add-Content -Path $HooksLog -Value "$timestamp $Name $ExitCode"
+  Copy-Item -Path $HooksLog -Destination $LogDir -Force
+}
+
+# Invoke-Hook runs the body of a hook, records the exit code of the hook and
+# exits with it. The body fails by throwing or by running a command that exits
+# with a non-zero code.
+function Invoke-Hook {
+  param([string]$Name, [scriptblock]$Body)
+
+  $exitCode = 0
+  try {
+    $global:LASTEXITCODE = 0
+    & $Body
+    $exitCode = $LASTEXITCODE
+  } catch {
+    [Console]::Error.WriteLine($_)
+    $exitCode = 1
+  }
+
+  Write-HookEvent $Name $exitCode
+  Exit $exitCode
+}
+
+# Wait-Running waits for the run process to record its pid, and fails unless it
+# is running.
+function Wait-Running {
+  for ($i = 0; $i -lt 60 -and -Not (Test-Path $PidFile); $i++) {
+    Start-Sleep -Seconds 1
+  }
+  Get-Process -Id ([int](Get-Content $PidFile)) | Out-Null
+}
@@ -0,0 +1,8 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+. (Join-Path $PSScriptRoot "hooks.ps1")
+
+Invoke-Hook "post-deploy" {
+  Wait-Running
+}
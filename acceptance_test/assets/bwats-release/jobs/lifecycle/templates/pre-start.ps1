@@ -0,0 +1,9 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+. (Join-Path $PSScriptRoot "hooks.ps1")
+
+Invoke-Hook "pre-start" {
+  New-Item -ItemType Directory -Force -Path $DataDir | Out-Null
+  Remove-Item -Force -ErrorAction Ignore $PidFile
+}
@@ -0,0 +1,18 @@
+$ErrorActionPreference = "Stop"
+
+. (Join-Path $PSScriptRoot "hooks.ps1")
+
+# The process records its pid, which the stop script stops it by. The run event
+# is the exit code of doing so: the process exits with it when it fails.
+try {
+  Set-Content -Path $PidFile -Value $PID
+} catch {
+  [Console]::Error.WriteLine($_)
+  Write-HookEvent "run" 1
+  Exit 1
+}
+Write-HookEvent "run" 0
+
+while($True) {
+  Start-Sleep -Seconds 1
+}
@@ -0,0 +1,9 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+. (Join-Path $PSScriptRoot "hooks.ps1")
+
+Invoke-Hook "stop" {
+  Stop-Process -Id ([int](Get-Content $PidFile)) -Force
+  Remove-Item $PidFile
+}
@@ -0,0 +1,44 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+function Get-Config {
+  $configPath = Join-Path $PSScriptRoot "config.json"
+  Write-Host "Loading '$configPath'"
+  $config = Get-Content $configPath -raw | ConvertFrom-Json
+  Write-Host "Loaded '$configPath'"
+  return $config
+}
+
+$config = Get-Config
+
+$LogDir = "C:\var\vcap\sys\log\link-consumer"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+function Resolve-Address {
+  param([string]$Address)
+
+  try {
+    $ips = @(Resolve-DnsName -Name $Address -Type A -DnsOnly -ErrorAction Stop | Where-Object { $_.IPAddress } | ForEach-Object { $_.IPAddress })
+    Write-Host "Resolved $Address to $($ips -join ', ')"
+    return @{ address = $Address; ips = $ips; error = "" }
+  } catch {
+    Write-Host "Unable to resolve $($Address): $_"
+    return @{ address = $Address; ips = @(); error = "$_" }
+  }
+}
+
+$Addresses = @($config.link.address) + @($config.link.instances | ForEach-Object { $_.address })
+$Resolved = @($Addresses | ForEach-Object { Resolve-Address $_ })
+
+$BoshEnv = @{}
+Get-ChildItem env: | Where-Object { $_.Name -like "BOSH_*" } | ForEach-Object { $BoshEnv[$_.Name] = $_.Value }
+
+$Report = @{
+  spec = $config.spec
+  link = $config.link
+  resolved = $Resolved
+  env = $BoshEnv
+}
+[System.IO.File]::WriteAllText((Join-Path $LogDir "links.json"), ($Report | ConvertTo-Json -Depth 5 -Compress))
+
+Exit 0
@@ -0,0 +1,14 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+# Records the BOSH_* environment the agent runs the drain script with. Nothing
+# else is written to stdout, which the agent reads the drain result from.
+$LogDir = "C:\var\vcap\sys\log\link-provider"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+$BoshEnv = @{}
+Get-ChildItem env: | Where-Object { $_.Name -like "BOSH_*" } | ForEach-Object { $BoshEnv[$_.Name] = $_.Value }
+[System.IO.File]::WriteAllText((Join-Path $LogDir "drain-env.json"), ($BoshEnv | ConvertTo-Json -Compress))
+
+Write-Output "0"
+Exit 0
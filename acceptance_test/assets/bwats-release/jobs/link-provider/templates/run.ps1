@@ -0,0 +1,3 @@
+while($True) {
+  Start-Sleep -Seconds 1
+}
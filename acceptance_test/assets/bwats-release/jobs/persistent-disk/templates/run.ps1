@@ -0,0 +1,60 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+function Get-Config {
+  $configPath = Join-Path $PSScriptRoot "config.json"
+  Write-Host "Loading '$configPath'"
+  $config = Get-Content $configPath -raw | ConvertFrom-Json
+  Write-Host "Loaded '$configPath'"
+  return $config
+}
+
+$config = Get-Config
+
+$LogDir = "C:\var\vcap\sys\log\persistent-disk"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+$StoreDir = "C:\var\vcap\store"
+$DataFile = Join-Path $StoreDir "persistent-disk\data.txt"
+
+# The agent mounts the persistent disk as a volume mount point on the store
+# directory, so the partition backing it has the store directory as an access path.
+$Partition = Get-Partition | Where-Object { $_.AccessPaths -contains "$StoreDir\" } | Select-Object -First 1
+
+$Report = @{
+  computer_name = $env:COMPUTERNAME
+  mounted = $Partition -ne $null
+  filesystem = ""
+  disk_size_bytes = 0
+  acls = @()
+  data = ""
+}
+
+if ($Partition -ne $null) {
+  $Report.filesystem = (Get-Volume -Partition $Partition).FileSystem
+  $Report.disk_size_bytes = (Get-Disk -Number $Partition.DiskNumber).Size
+  $Report.acls = @((Get-Acl $StoreDir).Access | ForEach-Object {
+    "$($_.IdentityReference),$($_.AccessControlType)"
+  } | Sort-Object -Unique)
+
+  if (Test-Path $DataFile) {
+    $Report.data = (Get-Content $DataFile -Raw).Trim()
+  }
+  Write-Host "Persistent disk is mounted at $StoreDir with $($Report.filesystem), found data: '$($Report.data)'"
+} else {
+  Write-Host "No persistent disk is mounted at $StoreDir"
+}
+
+[System.IO.File]::WriteAllText((Join-Path $LogDir "disk.json"), ($Report | ConvertTo-Json -Compress))
+
+if ($config.data -ne "") {
+  if ($Partition -eq $null) {
+    Write-Error "Cannot write data without a persistent disk mounted at $StoreDir"
+    Exit 1
+  }
+  New-Item -ItemType Directory -Force -Path (Split-Path $DataFile) | Out-Null
+  Set-Content -Path $DataFile -Value $config.data
+  Write-Host "Wrote data: '$($config.data)'"
+}
+
+Exit 0
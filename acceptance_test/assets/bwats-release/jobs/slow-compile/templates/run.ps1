@@ -1,3 +1,9 @@
+& "C:\var\vcap\packages\slow-compile\slow-compile.exe"
+if ($LASTEXITCODE -ne 0) {
+  Write-Error "slow-compile.exe failed with exit code $LASTEXITCODE"
+  Exit 1
+}
+
 $i = 1
 while($True) {
   Write-Host "$i seconds passed"
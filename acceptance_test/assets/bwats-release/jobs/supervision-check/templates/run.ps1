@@ -0,0 +1,39 @@
+$ErrorActionPreference = "Stop"
+trap { $host.SetShouldExit(1) }
+
+$PidsDir = "C:\var\vcap\data\supervision"
+$LogDir = "C:\var\vcap\sys\log\supervision-check"
+New-Item -ItemType Directory -Force -Path $LogDir | Out-Null
+
+function Test-Running {
+  param([int]$ProcessId)
+  # All the processes of the supervision job are PowerShell, which makes it
+  # unlikely that a reused pid is mistaken for one of them.
+  $process = Get-Process -Id $ProcessId -ErrorAction SilentlyContinue
+  return $process -ne $null -and $process.ProcessName -eq "powershell"
+}
+
+function Read-Lines {
+  param([string]$Path)
+  if (-Not (Test-Path $Path)) {
+    return @()
+  }
+  return @(Get-Content $Path | Where-Object { $_.Trim() -ne "" })
+}
+
+$Processes = @{}
+foreach ($name in @("crasher", "exiter", "stubborn", "parent")) {
+  $Processes[$name] = @(Read-Lines (Join-Path $PidsDir "$name.pids") | ForEach-Object {
+    @{ pid = [int]$_; running = (Test-Running $_) }
+  })
+}
+
+$Children = @(Read-Lines (Join-Path $PidsDir "children.pids") | ForEach-Object {
+  $parent, $child = $_ -split " "
+  @{ parent = [int]$parent; pid = [int]$child; running = (Test-Running $child) }
+})
+
+$Report = @{ processes = $Processes; children = $Children }
+[System.IO.File]::WriteAllText((Join-Path $LogDir "processes.json"), ($Report | ConvertTo-Json -Depth 4 -Compress))
+
+Exit 0
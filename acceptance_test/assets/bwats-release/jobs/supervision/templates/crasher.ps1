@@ -0,0 +1,10 @@
+$ErrorActionPreference = "Stop"
+
+. (Join-Path $PSScriptRoot "supervision.ps1")
+
+Register-Process "crasher"
+
+Start-Sleep -Seconds <%= p("supervision.fail_after_seconds") %>
+
+# An unhandled exception terminates the process without an explicit exit code.
+throw "crasher crashed"
@@ -0,0 +1,10 @@
+$ErrorActionPreference = "Stop"
+
+. (Join-Path $PSScriptRoot "supervision.ps1")
+
+Register-Process "exiter"
+
+Start-Sleep -Seconds <%= p("supervision.fail_after_seconds") %>
+
+Write-Host "exiter exiting with 42"
+Exit 42
@@ -0,0 +1,16 @@
+$ErrorActionPreference = "Stop"
+
+. (Join-Path $PSScriptRoot "supervision.ps1")
+
+Register-Process "parent"
+
+# Children record "<parent pid> <child pid>", so that the errand can check
+# that the children of stopped parents did not outlive them.
+$ChildrenFile = Join-Path $PidsDir "children.pids"
+for ($i = 0; $i -lt 2; $i++) {
+  $child = Start-Process powershell -ArgumentList "-NoProfile", "-Command", "while(`$True) { Start-Sleep -Seconds 1 }" -NoNewWindow -PassThru
+  Add-Content -Path $ChildrenFile -Value "$PID $($child.Id)"
+  Write-Host "parent started child with pid $($child.Id)"
+}
+
+Wait-Forever
@@ -0,0 +1,11 @@
+$ErrorActionPreference = "Stop"
+
+. (Join-Path $PSScriptRoot "supervision.ps1")
+
+Register-Process "stubborn"
+
+# Ignore Ctrl+C, so that stopping the process relies on job-service-wrapper
+# killing it once the stop timeout expires.
+[Console]::TreatControlCAsInput = $True
+
+Wait-Forever
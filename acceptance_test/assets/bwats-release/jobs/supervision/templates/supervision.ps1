@@ -0,0 +1,20 @@
+# Every process records its pid in C:\var\vcap\data\supervision\<process>.pids
+# when it starts, so that the supervision-check errand can tell how often it
+# was restarted and whether earlier instances are still running.
+$PidsDir = "C:\var\vcap\data\supervision"
+
+function Register-Process {
+  param([string]$Name)
+
+  New-Item -ItemType Directory -Force -Path $PidsDir | Out-Null
+  Add-Content -Path (Join-Path $PidsDir "$Name.pids") -Value $PID
+
+  Write-Host "$Name started with pid $PID"
+  [Console]::Error.WriteLine("$Name writes to stderr")
+}
+
+function Wait-Forever {
+  while($True) {
+    Start-Sleep -Seconds 1
+  }
+}
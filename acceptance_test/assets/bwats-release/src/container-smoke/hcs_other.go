@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "fmt"
+
+var errNotWindows = fmt.Errorf("containers are only supported on Windows")
+
+type scratchLayer struct{}
+
+func createScratchLayer(workDir, id, baseLayer string) (*scratchLayer, error) {
+	return nil, errNotWindows
+}
+
+func (l *scratchLayer) destroy() error {
+	return errNotWindows
+}
+
+type container struct{}
+
+func createContainer(id, owner string, scratch *scratchLayer, baseLayer string) (*container, error) {
+	return nil, errNotWindows
+}
+
+func (c *container) start() error {
+	return errNotWindows
+}
+
+func (c *container) run(command string) (string, int, error) {
+	return "", -1, errNotWindows
+}
+
+func (c *container) terminate() error {
+	return errNotWindows
+}
+
+func countComputeSystems(owner string) (int, error) {
+	return 0, errNotWindows
+}
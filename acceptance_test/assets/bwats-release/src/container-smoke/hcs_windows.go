@@ -0,0 +1,499 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The Host Compute Service (v1) and container layer APIs exported by
+// vmcompute.dll, as used by hcsshim.
+var (
+	vmcompute = syscall.NewLazyDLL("vmcompute.dll")
+
+	procHcsEnumerateComputeSystems         = vmcompute.NewProc("HcsEnumerateComputeSystems")
+	procHcsCreateComputeSystem             = vmcompute.NewProc("HcsCreateComputeSystem")
+	procHcsStartComputeSystem              = vmcompute.NewProc("HcsStartComputeSystem")
+	procHcsTerminateComputeSystem          = vmcompute.NewProc("HcsTerminateComputeSystem")
+	procHcsCloseComputeSystem              = vmcompute.NewProc("HcsCloseComputeSystem")
+	procHcsRegisterComputeSystemCallback   = vmcompute.NewProc("HcsRegisterComputeSystemCallback")
+	procHcsUnregisterComputeSystemCallback = vmcompute.NewProc("HcsUnregisterComputeSystemCallback")
+	procHcsCreateProcess                   = vmcompute.NewProc("HcsCreateProcess")
+	procHcsGetProcessProperties            = vmcompute.NewProc("HcsGetProcessProperties")
+	procHcsRegisterProcessCallback         = vmcompute.NewProc("HcsRegisterProcessCallback")
+	procHcsUnregisterProcessCallback       = vmcompute.NewProc("HcsUnregisterProcessCallback")
+	procHcsCloseProcess                    = vmcompute.NewProc("HcsCloseProcess")
+
+	procNameToGuid         = vmcompute.NewProc("NameToGuid")
+	procCreateSandboxLayer = vmcompute.NewProc("CreateSandboxLayer")
+	procActivateLayer      = vmcompute.NewProc("ActivateLayer")
+	procPrepareLayer       = vmcompute.NewProc("PrepareLayer")
+	procGetLayerMountPath  = vmcompute.NewProc("GetLayerMountPath")
+	procUnprepareLayer     = vmcompute.NewProc("UnprepareLayer")
+	procDeactivateLayer    = vmcompute.NewProc("DeactivateLayer")
+	procDestroyLayer       = vmcompute.NewProc("DestroyLayer")
+)
+
+const (
+	hcsOperationPending     = 0xC0370103
+	hcsSystemAlreadyStopped = 0xC0370110
+
+	notificationSystemExited          = 0x00000001
+	notificationSystemCreateCompleted = 0x00000002
+	notificationSystemStartCompleted  = 0x00000003
+	notificationProcessExited         = 0x00010000
+
+	// filterDriver is the flavour of layers used by process-isolated containers.
+	filterDriver = 1
+
+	operationTimeout = 5 * time.Minute
+)
+
+type notification struct {
+	kind   uint32
+	status int32
+	data   string
+}
+
+// Notifications are delivered on threads owned by HCS, and routed to the
+// waiting handle by the context registered with the callback.
+var (
+	notificationsLock    sync.Mutex
+	notificationChannels = map[uintptr]chan notification{}
+	nextContext          uintptr
+	notificationCallback = syscall.NewCallback(func(kind, context, status, data uintptr) uintptr {
+		n := notification{kind: uint32(kind), status: int32(status), data: utf16PtrToString(*(**uint16)(unsafe.Pointer(&data)))}
+
+		notificationsLock.Lock()
+		ch := notificationChannels[context]
+		notificationsLock.Unlock()
+
+		if ch != nil {
+			ch <- n
+		}
+		return 0
+	})
+)
+
+func registerNotifications() (uintptr, chan notification) {
+	notificationsLock.Lock()
+	defer notificationsLock.Unlock()
+
+	nextContext++
+	ch := make(chan notification, 16)
+	notificationChannels[nextContext] = ch
+	return nextContext, ch
+}
+
+func unregisterNotifications(context uintptr) {
+	notificationsLock.Lock()
+	defer notificationsLock.Unlock()
+
+	delete(notificationChannels, context)
+}
+
+func waitForNotification(ch chan notification, kind uint32) error {
+	timeout := time.After(operationTimeout)
+	for {
+		select {
+		case n := <-ch:
+			if n.kind == kind {
+				if n.status < 0 {
+					return fmt.Errorf("HCS notification 0x%x failed with 0x%08x: %s", kind, uint32(n.status), n.data)
+				}
+				return nil
+			}
+			if n.kind == notificationSystemExited {
+				return fmt.Errorf("compute system exited while waiting for notification 0x%x: %s", kind, n.data)
+			}
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for HCS notification 0x%x", kind)
+		}
+	}
+}
+
+func utf16PtrToString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+
+	var chars []uint16
+	for ptr := unsafe.Pointer(p); *(*uint16)(ptr) != 0; ptr = unsafe.Pointer(uintptr(ptr) + 2) {
+		chars = append(chars, *(*uint16)(ptr))
+	}
+	return syscall.UTF16ToString(chars)
+}
+
+func utf16Ptr(s string) uintptr {
+	p, err := syscall.UTF16PtrFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return uintptr(unsafe.Pointer(p))
+}
+
+// hcsError converts the HRESULT returned by an HCS function, along with its
+// result document, to an error.
+func hcsError(proc *syscall.LazyProc, hr uintptr, result *uint16) error {
+	if hr == 0 {
+		return nil
+	}
+	return &hresultError{name: proc.Name, hr: uint32(hr), result: utf16PtrToString(result)}
+}
+
+type hresultError struct {
+	name   string
+	hr     uint32
+	result string
+}
+
+func (e *hresultError) Error() string {
+	return fmt.Sprintf("%s failed with HRESULT 0x%08x: %s", e.name, e.hr, e.result)
+}
+
+func isHresult(err error, hr uint32) bool {
+	e, ok := err.(*hresultError)
+	return ok && e.hr == hr
+}
+
+type guid struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+func (g guid) String() string {
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", g.Data1, g.Data2, g.Data3, g.Data4[:2], g.Data4[2:])
+}
+
+// layerID is the GUID HCS identifies a layer with, derived from its folder name.
+func layerID(path string) (guid, error) {
+	var g guid
+	hr, _, _ := procNameToGuid.Call(utf16Ptr(filepath.Base(path)), uintptr(unsafe.Pointer(&g)))
+	return g, hcsError(procNameToGuid, hr, nil)
+}
+
+type driverInfo struct {
+	Flavour  int
+	HomeDirp *uint16
+}
+
+type layerDescriptor struct {
+	LayerID guid
+	Flags   uint32
+	Pathp   *uint16
+}
+
+type scratchLayer struct {
+	info        driverInfo
+	id          string
+	path        string
+	mountPath   string
+	descriptors []layerDescriptor
+	activated   bool
+	prepared    bool
+}
+
+func layerCall(proc *syscall.LazyProc, args ...uintptr) error {
+	hr, _, _ := proc.Call(args...)
+	return hcsError(proc, hr, nil)
+}
+
+// createScratchLayer creates, activates and mounts the writable layer of the
+// container on top of the base layer.
+func createScratchLayer(workDir, id, baseLayer string) (*scratchLayer, error) {
+	homeDir, err := syscall.UTF16PtrFromString(workDir)
+	if err != nil {
+		return nil, err
+	}
+	basePath, err := syscall.UTF16PtrFromString(baseLayer)
+	if err != nil {
+		return nil, err
+	}
+	baseID, err := layerID(baseLayer)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &scratchLayer{
+		info:        driverInfo{Flavour: filterDriver, HomeDirp: homeDir},
+		id:          id,
+		path:        filepath.Join(workDir, id),
+		descriptors: []layerDescriptor{{LayerID: baseID, Pathp: basePath}},
+	}
+	info := uintptr(unsafe.Pointer(&l.info))
+	descriptors := uintptr(unsafe.Pointer(&l.descriptors[0]))
+
+	if err := layerCall(procCreateSandboxLayer, info, utf16Ptr(id), 0, descriptors, uintptr(len(l.descriptors))); err != nil {
+		return nil, err
+	}
+
+	if err := layerCall(procActivateLayer, info, utf16Ptr(id)); err != nil {
+		return l, err
+	}
+	l.activated = true
+
+	if err := layerCall(procPrepareLayer, info, utf16Ptr(id), descriptors, uintptr(len(l.descriptors))); err != nil {
+		return l, err
+	}
+	l.prepared = true
+
+	var length uintptr
+	if err := layerCall(procGetLayerMountPath, info, utf16Ptr(id), uintptr(unsafe.Pointer(&length)), 0); err != nil {
+		return l, err
+	}
+	buffer := make([]uint16, length)
+	if err := layerCall(procGetLayerMountPath, info, utf16Ptr(id), uintptr(unsafe.Pointer(&length)), uintptr(unsafe.Pointer(&buffer[0]))); err != nil {
+		return l, err
+	}
+	l.mountPath = syscall.UTF16ToString(buffer)
+
+	return l, nil
+}
+
+// destroy unmounts, deactivates and deletes the scratch layer.
+func (l *scratchLayer) destroy() error {
+	if l == nil {
+		return nil
+	}
+	info := uintptr(unsafe.Pointer(&l.info))
+
+	if l.prepared {
+		if err := layerCall(procUnprepareLayer, info, utf16Ptr(l.id)); err != nil {
+			return err
+		}
+		l.prepared = false
+	}
+	if l.activated {
+		if err := layerCall(procDeactivateLayer, info, utf16Ptr(l.id)); err != nil {
+			return err
+		}
+		l.activated = false
+	}
+	return layerCall(procDestroyLayer, info, utf16Ptr(l.id))
+}
+
+type container struct {
+	handle        syscall.Handle
+	callback      syscall.Handle
+	context       uintptr
+	notifications chan notification
+}
+
+type containerConfig struct {
+	SystemType              string
+	Name                    string
+	Owner                   string
+	VolumePath              string
+	IgnoreFlushesDuringBoot bool
+	LayerFolderPath         string
+	Layers                  []containerLayer
+	HostName                string
+	HvPartition             bool
+}
+
+type containerLayer struct {
+	ID   string
+	Path string
+}
+
+func createContainer(id, owner string, scratch *scratchLayer, baseLayer string) (*container, error) {
+	baseID, err := layerID(baseLayer)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := json.Marshal(containerConfig{
+		SystemType:              "Container",
+		Name:                    id,
+		Owner:                   owner,
+		VolumePath:              scratch.mountPath,
+		IgnoreFlushesDuringBoot: true,
+		LayerFolderPath:         scratch.path,
+		Layers:                  []containerLayer{{ID: baseID.String(), Path: baseLayer}},
+		HostName:                "bwats-container",
+		HvPartition:             false,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &container{}
+	var result *uint16
+	hr, _, _ := procHcsCreateComputeSystem.Call(utf16Ptr(id), utf16Ptr(string(config)), 0,
+		uintptr(unsafe.Pointer(&c.handle)), uintptr(unsafe.Pointer(&result)))
+	createErr := hcsError(procHcsCreateComputeSystem, hr, result)
+	if createErr != nil && !isHresult(createErr, hcsOperationPending) {
+		return nil, createErr
+	}
+
+	c.context, c.notifications = registerNotifications()
+	hr, _, _ = procHcsRegisterComputeSystemCallback.Call(uintptr(c.handle), notificationCallback, c.context, uintptr(unsafe.Pointer(&c.callback)))
+	if err := hcsError(procHcsRegisterComputeSystemCallback, hr, nil); err != nil {
+		c.close()
+		return nil, err
+	}
+
+	if createErr != nil {
+		if err := waitForNotification(c.notifications, notificationSystemCreateCompleted); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *container) start() error {
+	var result *uint16
+	hr, _, _ := procHcsStartComputeSystem.Call(uintptr(c.handle), 0, uintptr(unsafe.Pointer(&result)))
+	err := hcsError(procHcsStartComputeSystem, hr, result)
+	if isHresult(err, hcsOperationPending) {
+		return waitForNotification(c.notifications, notificationSystemStartCompleted)
+	}
+	return err
+}
+
+type processParameters struct {
+	CommandLine      string
+	WorkingDirectory string
+	CreateStdOutPipe bool
+	CreateStdErrPipe bool
+}
+
+type processInformation struct {
+	ProcessID uint32
+	Reserved  uint32
+	StdInput  syscall.Handle
+	StdOutput syscall.Handle
+	StdError  syscall.Handle
+}
+
+type processProperties struct {
+	Exited   bool
+	ExitCode int
+}
+
+// run runs a command in the container, returning its combined output and exit code.
+func (c *container) run(command string) (string, int, error) {
+	params, err := json.Marshal(processParameters{
+		CommandLine:      command,
+		WorkingDirectory: `C:\`,
+		CreateStdOutPipe: true,
+		CreateStdErrPipe: true,
+	})
+	if err != nil {
+		return "", -1, err
+	}
+
+	var info processInformation
+	var process syscall.Handle
+	var result *uint16
+	hr, _, _ := procHcsCreateProcess.Call(uintptr(c.handle), utf16Ptr(string(params)),
+		uintptr(unsafe.Pointer(&info)), uintptr(unsafe.Pointer(&process)), uintptr(unsafe.Pointer(&result)))
+	if err := hcsError(procHcsCreateProcess, hr, result); err != nil {
+		return "", -1, err
+	}
+	defer procHcsCloseProcess.Call(uintptr(process)) //nolint:errcheck
+
+	context, notifications := registerNotifications()
+	defer unregisterNotifications(context)
+	var callback syscall.Handle
+	hr, _, _ = procHcsRegisterProcessCallback.Call(uintptr(process), notificationCallback, context, uintptr(unsafe.Pointer(&callback)))
+	if err := hcsError(procHcsRegisterProcessCallback, hr, nil); err != nil {
+		return "", -1, err
+	}
+	defer procHcsUnregisterProcessCallback.Call(uintptr(callback)) //nolint:errcheck
+
+	// Read the pipes while the process runs, so that it cannot block on a full pipe.
+	var wg sync.WaitGroup
+	outputs := make([][]byte, 2)
+	for i, handle := range []syscall.Handle{info.StdOutput, info.StdError} {
+		wg.Add(1)
+		go func(i int, f *os.File) {
+			defer wg.Done()
+			defer f.Close() //nolint:errcheck
+			outputs[i], _ = ioutil.ReadAll(f)
+		}(i, os.NewFile(uintptr(handle), fmt.Sprintf("container-pipe-%d", i)))
+	}
+	syscall.CloseHandle(info.StdInput) //nolint:errcheck
+
+	if err := waitForNotification(notifications, notificationProcessExited); err != nil {
+		return "", -1, err
+	}
+	wg.Wait()
+	output := string(outputs[0]) + string(outputs[1])
+
+	var properties *uint16
+	hr, _, _ = procHcsGetProcessProperties.Call(uintptr(process), uintptr(unsafe.Pointer(&properties)), uintptr(unsafe.Pointer(&result)))
+	if err := hcsError(procHcsGetProcessProperties, hr, result); err != nil {
+		return output, -1, err
+	}
+
+	var p processProperties
+	if err := json.Unmarshal([]byte(utf16PtrToString(properties)), &p); err != nil {
+		return output, -1, err
+	}
+	return output, p.ExitCode, nil
+}
+
+// terminate stops the container and closes its handle.
+func (c *container) terminate() error {
+	defer c.close()
+
+	var result *uint16
+	hr, _, _ := procHcsTerminateComputeSystem.Call(uintptr(c.handle), 0, uintptr(unsafe.Pointer(&result)))
+	err := hcsError(procHcsTerminateComputeSystem, hr, result)
+	switch {
+	case isHresult(err, hcsOperationPending):
+		return waitForNotification(c.notifications, notificationSystemExited)
+	case isHresult(err, hcsSystemAlreadyStopped):
+		return nil
+	}
+	return err
+}
+
+func (c *container) close() {
+	if c.callback != 0 {
+		procHcsUnregisterComputeSystemCallback.Call(uintptr(c.callback)) //nolint:errcheck
+		c.callback = 0
+	}
+	if c.context != 0 {
+		unregisterNotifications(c.context)
+		c.context = 0
+	}
+	if c.handle != 0 {
+		procHcsCloseComputeSystem.Call(uintptr(c.handle)) //nolint:errcheck
+		c.handle = 0
+	}
+}
+
+// countComputeSystems returns how many compute systems with the given owner exist.
+func countComputeSystems(owner string) (int, error) {
+	query, err := json.Marshal(map[string][]string{"Owners": {owner}})
+	if err != nil {
+		return 0, err
+	}
+
+	var computeSystems, result *uint16
+	hr, _, _ := procHcsEnumerateComputeSystems.Call(utf16Ptr(string(query)),
+		uintptr(unsafe.Pointer(&computeSystems)), uintptr(unsafe.Pointer(&result)))
+	if err := hcsError(procHcsEnumerateComputeSystems, hr, result); err != nil {
+		return 0, err
+	}
+
+	var systems []json.RawMessage
+	if err := json.Unmarshal([]byte(utf16PtrToString(computeSystems)), &systems); err != nil {
+		return 0, err
+	}
+	return len(systems), nil
+}
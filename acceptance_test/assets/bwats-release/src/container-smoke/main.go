@@ -0,0 +1,117 @@
+// container-smoke runs a command in a process-isolated Windows container,
+// created with the Host Compute Service from a base layer, and cleans up after
+// it. It writes what happened to a JSON report.
+//
+// It is built with the golang-windows package, so it only uses the standard
+// library of that Go version.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const owner = "bwats"
+
+type report struct {
+	Created                 bool   `json:"created"`
+	Started                 bool   `json:"started"`
+	Command                 string `json:"command"`
+	ExitCode                int    `json:"exit_code"`
+	Output                  string `json:"output"`
+	Terminated              bool   `json:"terminated"`
+	LayersCleanedUp         bool   `json:"layers_cleaned_up"`
+	RemainingComputeSystems int    `json:"remaining_compute_systems"`
+	Error                   string `json:"error"`
+}
+
+func main() {
+	baseLayer := flag.String("base-layer", "", "path to the base layer of the container")
+	workDir := flag.String("work-dir", "", "directory the scratch layer of the container is created in")
+	command := flag.String("command", "cmd /c echo bwats container smoke test", "command run in the container")
+	reportPath := flag.String("report", "container.json", "path the JSON report is written to")
+	flag.Parse()
+
+	r := report{Command: *command, ExitCode: -1}
+	err := run(&r, *baseLayer, *workDir)
+	if err != nil {
+		r.Error = err.Error()
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	body, marshalErr := json.Marshal(r)
+	if marshalErr != nil {
+		fmt.Fprintln(os.Stderr, marshalErr)
+		os.Exit(1)
+	}
+	if writeErr := ioutil.WriteFile(*reportPath, body, 0644); writeErr != nil {
+		fmt.Fprintln(os.Stderr, writeErr)
+		os.Exit(1)
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// run creates the container, runs the command and always attempts to clean
+// up, reporting the first error.
+func run(r *report, baseLayer, workDir string) (err error) {
+	if baseLayer == "" || workDir == "" {
+		return fmt.Errorf("-base-layer and -work-dir are required")
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return err
+	}
+
+	id := fmt.Sprintf("bwats-%d", os.Getpid())
+	scratch, err := createScratchLayer(workDir, id, baseLayer)
+	if err != nil {
+		// A partially set up layer is still returned, to be cleaned up.
+		scratch.destroy() //nolint:errcheck
+		return err
+	}
+	defer func() {
+		cleanupErr := scratch.destroy()
+		if cleanupErr == nil {
+			_, statErr := os.Stat(filepath.Join(workDir, id))
+			r.LayersCleanedUp = os.IsNotExist(statErr)
+		}
+		if err == nil {
+			err = cleanupErr
+		}
+	}()
+
+	container, err := createContainer(id, owner, scratch, baseLayer)
+	if err != nil {
+		return err
+	}
+	r.Created = true
+	defer func() {
+		terminateErr := container.terminate()
+		r.Terminated = terminateErr == nil
+		if err == nil {
+			err = terminateErr
+		}
+
+		remaining, enumerateErr := countComputeSystems(owner)
+		r.RemainingComputeSystems = remaining
+		if err == nil {
+			err = enumerateErr
+		}
+	}()
+
+	if err := container.start(); err != nil {
+		return err
+	}
+	r.Started = true
+
+	output, exitCode, err := container.run(r.Command)
+	r.Output = output
+	r.ExitCode = exitCode
+	return err
+}
@@ -0,0 +1,31 @@
+$config = Get-Config
+$expectedacls = @($config.expectations_acls_identities | ForEach-Object { [Environment]::ExpandEnvironmentVariables($_) })
+
+function Check-Acls {
+    param([string]$path)
+
+    $errCount = 0
+
+    Get-ChildItem -Path $path -Recurse | foreach {
+      $name = $_.FullName
+      If (-Not ($_.Attributes -match "ReparsePoint")) {
+        Get-Acl $name | Select -ExpandProperty Access | ForEach-Object {
+          $ident = ('{0},{1}' -f $_.IdentityReference, $_.AccessControlType).ToString()
+          If ($expectedacls -notcontains $ident) {
+            $errCount += 1
+            Write-Host "Error ($name): $ident"
+          }
+        }
+      }
+    }
+    return $errCount
+}
+
+$errCount = 0
+foreach ($path in $config.expectations_acls_paths) {
+  $errCount += Check-Acls $path
+}
+if ($errCount -ne 0) {
+    Write-Error "FAILED: $errCount"
+    Exit 1
+}
@@ -0,0 +1,32 @@
+$agent = Get-Service | Where { $_.Name -eq 'bosh-agent' }
+if ($agent -eq $null) {
+    Write-Error "Missing service: bosh-agent"
+    Exit 1
+}
+if ($agent.StartType -ne "Automatic") {
+    Write-Error "verify-agent-start-type: bosh-agent start type is not 'Automatic' got: '$($agent.StartType.ToString())'"
+    Exit 1
+}
+
+$RegPath="HKLM:\SYSTEM\CurrentControlSet\Services\bosh-agent"
+
+if ((Get-ItemProperty  $RegPath).DelayedAutostart -ne 1) {
+    Write-Error "verify-agent-start-type: Expected DelayedAutostart to equal 1"
+    Exit 1
+}
+
+$ServicesPipeTimeoutPath = "HKLM:\SYSTEM\CurrentControlSet\Control"
+if ((Get-ItemProperty  $ServicesPipeTimeoutPath).ServicesPipeTimeout -ne 60000) {
+    Write-Error "Error: expected ServicesPipeTimeout to equal 60s"
+    Exit 1
+}
+
+if ((Get-Service wuauserv).Status -ne "Stopped") {
+    Write-Error "Error: expected wuauserv service to be Stopped"
+    Exit 1
+}
+
+$StartType = (Get-Service wuauserv).StartType
+if ($StartType -ne "Disabled") {
+    Write-Host "Warning: wuauserv service StartType is not disabled: ${StartType}"
+}
@@ -0,0 +1,12 @@
+$config = Get-Config
+$validatePolicies = if ($config.security_compliance_expected_to_comply -eq $true) { $True } else { $False }
+
+if ( $validatePolicies )
+{
+  Import-Module C:\var\vcap\packages\pester\Pester\Pester.psd1
+  $pesterResults = Invoke-Pester $PSScriptRoot/AuditPolicies.Tests.ps1 -PassThru
+  if ($pesterResults.FailedCount -gt 0)
+  {
+    Exit 1
+  }
+}
@@ -0,0 +1,23 @@
+function get-firewall {
+  param([string] $profile)
+  $firewall = (Get-NetFirewallProfile -Name $profile)
+  $result = "{0},{1},{2}" -f $profile,$firewall.DefaultInboundAction,$firewall.DefaultOutboundAction
+  return $result
+}
+
+function check-firewall {
+  param($expected)
+  $profile = $expected.name
+  $firewall = (get-firewall $profile)
+  Write-Host $firewall
+  if ($firewall -ne ("{0},{1},{2}" -f $profile,$expected.default_inbound_action,$expected.default_outbound_action)) {
+    Write-Host $firewall
+    Write-Error "Unable to set $profile Profile"
+    Exit 1
+  }
+}
+
+$config = Get-Config
+foreach ($expectedProfile in $config.expectations_firewall_profiles) {
+  check-firewall $expectedProfile
+}
@@ -0,0 +1,9 @@
+$feature = Get-WindowsOptionalFeature -Online -FeatureName Microsoft-Hyper-V
+
+if ($feature.State -ne "Enabled") {
+  Write-Error "Hyper-V is NOT enabled"
+  Get-WindowsOptionalFeature -Online -FeatureName Microsoft-Hyper-V
+  Exit 1
+}
+
+Write-Host "Hyper-V is enabled"
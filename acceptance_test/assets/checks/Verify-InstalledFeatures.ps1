@@ -0,0 +1,26 @@
+function Assert-IsInstalled {
+  param (
+    [string] $feature= (Throw "feature param required")
+  )
+  If (!(Get-WindowsFeature $feature).Installed) {
+    Write-Error "Failed to find $feature"
+    Exit 1
+  } else {
+    Write-Host "Found $feature feature"
+  }
+}
+function Assert-IsNotInstalled {
+  param (
+    [string] $feature= (Throw "feature param required")
+  )
+  If (!(Get-WindowsFeature $feature).Installed) {
+    Write-Host "Feature $feature is not installed"
+  } else {
+    Write-Error "Feature $feature is installed"
+    Exit 1
+  }
+}
+
+$config = Get-Config
+$config.expectations_features_installed | ForEach-Object { Assert-IsInstalled $_ }
+$config.expectations_features_not_installed | ForEach-Object { Assert-IsNotInstalled $_ }
@@ -0,0 +1,70 @@
+echo "Running this function Verify-LGPO"
+echo "Verifying that expected policies have been applied"
+
+lgpo /b $PSScriptRoot
+$LgpoDir = "$PSScriptRoot\" + (Get-ChildItem $PSScriptRoot -Directory | ?{ $_.Name -match "{*}" } | select -First 1).Name
+
+$OutputDir = "$PSScriptRoot\lgpo_test"
+mkdir $OutputDir
+
+lgpo /parse /m "$LgpoDir\DomainSysvol\GPO\Machine\registry.pol" > "$OutputDir\machine_registry.unedited.txt"
+Get-Content "$OutputDir\machine_registry.unedited.txt" | select -Skip 3 > "$OutputDir\machine_registry.txt"
+
+lgpo /parse /u "$LgpoDir\DomainSysvol\GPO\User\registry.pol" > "$OutputDir\user_registry.unedited.txt"
+Get-Content "$OutputDir\user_registry.unedited.txt" | select -Skip 3 > "$OutputDir\user_registry.txt"
+
+copy "$LgpoDir\DomainSysvol\GPO\Machine\microsoft\windows nt\Audit\audit.csv" "$OutputDir"
+$Csv = Import-Csv "$LgpoDir\DomainSysvol\GPO\Machine\microsoft\windows nt\Audit\audit.csv"
+$Include = $Csv[0].psobject.properties | select -ExpandProperty Name -Skip 1
+$Csv | select $Include | export-csv "$OutputDir\audit.csv" -NoTypeInformation
+
+copy "$LgpoDir\DomainSysvol\GPO\Machine\microsoft\windows nt\SecEdit\GptTmpl.inf" "$OutputDir"
+
+function Compare-LGPOPolicies
+{
+  Param (
+    [string] $ActualPoliciesFile = (Throw "ActualPoliciesFile param required"),
+    [string] $ExpectedPoliciesFile = (Throw "ExpectedPoliciesFile param required"),
+    [string] $PolicyDelimiter = (Throw "PolicyDelimiter param required")
+  )
+  Write-Host "actual policies $ActualPoliciesFile"
+  Write-Host "expected policies $ExpectedPoliciesFile"
+
+  $delims = [char[]]"`r`n`t "
+  $ActualPolicies = (Get-Content $ActualPoliciesFile -Raw).Replace("`r`n","`n")
+  $ActualPoliciesArray = ( [regex]::split($ActualPolicies, $PolicyDelimiter) | foreach {
+  $_.Trim($delims)
+  } )
+
+  $ExpectedPolicies = (Get-Content $ExpectedPoliciesFile -Raw).Replace("`r`n","`n")
+  $ExpectedPoliciesArray = ( [regex]::split($ExpectedPolicies, $PolicyDelimiter) | foreach {
+  $_.Trim($delims)
+  } )
+
+  $count = 0
+  foreach ($policy in $ExpectedPoliciesArray) {
+  if ($policy -notin $ActualPoliciesArray) {
+  Write-Error "Actual policies do not include policy: $policy"
+  $count += 1
+  }
+  }
+  if (-not $count -eq 0) {
+  Write-Error "There are missing policies"
+  return 1
+  }
+}
+
+$newLineDelimiter = [System.Environment]::NewLine
+
+$OsVersion = Get-OSVersion
+switch ($OsVersion)
+{
+  "windows2019" {
+    $TestDir = "$PSScriptRoot\..\test-2019"
+  }
+}
+
+Compare-LGPOPolicies "$OutputDir\machine_registry.txt" "$TestDir\machine_registry.txt" "\n\n"
+Compare-LGPOPolicies "$OutputDir\user_registry.txt" "$TestDir\user_registry.txt" "\n\n"
+Compare-LGPOPolicies "$OutputDir\GptTmpl.inf" "$TestDir\GptTmpl.inf" "\n"
+Compare-LGPOPolicies "$OutputDir\audit.csv" "$TestDir\audit.csv" "\n"
@@ -0,0 +1,16 @@
+$MetadataServerAllowRules = Get-NetFirewallRule -Enabled True -Direction Outbound | Get-NetFirewallAddressFilter | Where-Object -FilterScript { $_.RemoteAddress -Eq '169.254.169.254' }
+If ($MetadataServerAllowRules -Ne $null) {
+  $ExpectedRuleNames = @((Get-Config).expectations_firewall_metadata_server_rules)
+  $RuleNames = @($MetadataServerAllowRules | foreach { $_.InstanceID })
+  If ($RuleNames.Count -ne $ExpectedRuleNames.Count ) {
+    Write-Error "Expected $($ExpectedRuleNames.Count) firewall rules"
+    $RuleNames
+    Exit 1
+  }
+  foreach ($ExpectedRuleName in $ExpectedRuleNames) {
+    If ($RuleNames -notcontains $ExpectedRuleName) {
+      Write-Error "Did not find rule $ExpectedRuleName"
+      Exit 1
+    }
+  }
+}
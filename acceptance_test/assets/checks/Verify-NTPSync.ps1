@@ -0,0 +1,26 @@
+echo "Verifying NTP sync works correctly"
+w32tm /query /configuration
+
+Set-Date -Date (Get-Date).AddHours(-8)
+$OutOfSyncTime = Get-Date
+
+$TimeSetCorrectly = $false
+
+for ($i=0; $i -lt 10; $i++) {
+    Sleep 1
+
+    w32tm /resync /rediscover
+    w32tm /resync
+
+    if ((Get-Date) -le $OutOfSyncTime) {
+        Write-Host "Time not reset correctly via NTP on attempt $($i+1) of 10: $(Get-Date) less than or equal to $OutOfSyncTime"
+    } else {
+        $TimeSetCorrectly = $true
+        break
+    }
+}
+
+if (-not $TimeSetCorrectly) {
+    Write-Error "Time not reset correctly via NTP after 10 attempts"
+    Exit 1
+}
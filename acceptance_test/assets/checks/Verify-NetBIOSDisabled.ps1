@@ -0,0 +1,7 @@
+$DisabledNetBIOS = $false
+$nbtstat = nbtstat.exe -n
+"results for nbtstat: $nbtstat"
+
+$nbtstat | foreach {
+    $DisabledNetBIOS = $DisabledNetBIOS -or $_ -like '*No names in cache*'
+}
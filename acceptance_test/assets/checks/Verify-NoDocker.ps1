@@ -0,0 +1,9 @@
+try {
+  docker ps
+} catch {
+  Write-Host "Docker is not installed"
+  return
+}
+
+Write-Error "Docker is installed. It shouldn't be!"
+Exit 1
@@ -0,0 +1,8 @@
+$PSMajorVersion = $PSVersionTable.PSVersion.Major
+
+if ($PSMajorVersion -lt 5) {
+  Write-Error "Powershell Major version is $PSMajorVersion. It should be at least 5"
+  Exit 1
+}
+
+Write-Host "Powershell is up to date: Version is: $($PSVersiontable.PSversion)"
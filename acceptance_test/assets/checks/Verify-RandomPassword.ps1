@@ -0,0 +1,13 @@
+secedit /configure /db secedit.sdb /cfg c:\var\vcap\jobs\check-system\inf\security.inf
+
+Add-Type -AssemblyName System.DirectoryServices.AccountManagement
+$ComputerName=hostname
+$DS = New-Object System.DirectoryServices.AccountManagement.PrincipalContext('machine',$ComputerName)
+
+$config = Get-Config
+$DefaultUsername = $config.password_default_username
+$DefaultPassword = $config.password_default_password
+if ($DS.ValidateCredentials($DefaultUsername, $DefaultPassword)) {
+    Write-Error "$DefaultUsername password was not randomized"
+    Exit 1
+}
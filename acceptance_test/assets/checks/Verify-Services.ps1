@@ -0,0 +1,20 @@
+$config = Get-Config
+$SSH_Disabled = if ($config.ssh_disabled_by_default -eq $true) { $True } else { $False }
+
+foreach ($service in $config.expectations_services_stopped) {
+  If ( (Get-Service $service).Status -ne "Stopped") {
+    $msg = "$service is not Stopped. It is {0}" -f $(Get-Service $service).Status
+    Write-Error $msg
+    Exit 1
+  }
+}
+
+$startype = If ($SSH_DISABLED) {"Disabled"} Else {"Automatic"}
+
+foreach ($service in $config.expectations_services_ssh) {
+  If ( (Get-Service $service).StartType -ne $startype) {
+    $msg = "$service service start type is not ${startype}. It is {0}" -f $(Get-Service $service).StartType
+    Write-Error $msg
+    Exit 1
+  }
+}
@@ -0,0 +1,6 @@
+# something about GCP
+$timezone = Get-TimeZone
+if ($timezone.Id -ne "UTC") {
+  Write-Error "Timezone is $($timezone.Id), but should be: UTC"
+  Exit 1
+}
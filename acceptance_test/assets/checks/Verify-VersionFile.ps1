@@ -0,0 +1,8 @@
+$VersionFileExists = Test-Path "C:\\var\\vcap\\bosh\\etc\\stemcell_version" -PathType Leaf
+
+if (-Not $VersionFileExists) {
+  Write-Error "Version file does not exits at path C:\\var\\vcap\\bosh\\etc\\stemcell_version"
+  Exit 1
+}
+
+Write-Host "Version file exists at path C:\\var\\vcap\\bosh\\etc\\stemcell_version"
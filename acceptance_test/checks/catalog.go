@@ -0,0 +1,216 @@
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Catalog declares the check-system errand: its static job spec and the checks
+// it runs. It is rendered into the job by Render.
+type Catalog struct {
+	Job      Job      `yaml:"job"`
+	Profiles []string `yaml:"profiles"`
+	Checks   []Check  `yaml:"checks"`
+}
+
+type Job struct {
+	Name        string        `yaml:"name"`
+	Description string        `yaml:"description"`
+	Templates   yaml.MapSlice `yaml:"templates"`
+	Packages    []string      `yaml:"packages"`
+	Properties  []Property    `yaml:"properties"`
+}
+
+type Check struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// OS restricts the check to the given profiles. It runs on all of them when empty.
+	OS []string `yaml:"os"`
+	// Body is the path, relative to the catalog, of the PowerShell run as the
+	// body of the check function.
+	Body       string     `yaml:"body"`
+	Properties []Property `yaml:"properties"`
+	// ExpectedOutput is a regular expression the output of the check must match.
+	ExpectedOutput string `yaml:"expected_output"`
+	// Manual checks only run when selected by checks.focus.
+	Manual bool `yaml:"manual"`
+
+	script string
+}
+
+type Property struct {
+	Name        string
+	Description string
+	Default     interface{}
+}
+
+// UnmarshalYAML keeps the key order of maps in the default value, so that it
+// is rendered into the job spec as written in the catalog.
+func (p *Property) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var fields yaml.MapSlice
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		var ok bool
+		switch field.Key {
+		case "name":
+			p.Name, ok = field.Value.(string)
+		case "description":
+			p.Description, ok = field.Value.(string)
+		case "default":
+			p.Default, ok = field.Value, true
+		default:
+			return fmt.Errorf("field %v not found in type checks.Property", field.Key)
+		}
+		if !ok {
+			return fmt.Errorf("property field %v must be a string", field.Key)
+		}
+	}
+
+	return nil
+}
+
+// ConfigKey is the key of the property in the errand's config.json.
+func (p Property) ConfigKey() string {
+	return strings.ReplaceAll(p.Name, ".", "_")
+}
+
+var (
+	checkNameRegexp    = regexp.MustCompile(`^[A-Z][A-Za-z]*-[A-Za-z0-9]+$`)
+	propertyNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)*$`)
+)
+
+func LoadCatalog(path string) (*Catalog, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Catalog
+	if err := yaml.UnmarshalStrict(body, &c); err != nil {
+		return nil, fmt.Errorf("unable to parse check catalog '%s': %v", path, err)
+	}
+
+	for i, check := range c.Checks {
+		if check.Body == "" {
+			continue
+		}
+		script, err := os.ReadFile(filepath.Join(filepath.Dir(path), check.Body))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read body of %s: %v", check.Name, err)
+		}
+		c.Checks[i].script = string(script)
+	}
+
+	return &c, nil
+}
+
+// Validate reports every problem with the catalog, so they can all be fixed at once.
+func (c *Catalog) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Job.Name == "" {
+		fail("job name is required")
+	}
+	if len(c.Profiles) == 0 {
+		fail("at least one profile is required")
+	}
+
+	names := map[string]bool{}
+	for _, check := range c.Checks {
+		if !checkNameRegexp.MatchString(check.Name) {
+			fail("check name '%s' is not a PowerShell Verb-Noun name", check.Name)
+		}
+		if names[check.Name] {
+			fail("check %s is declared more than once", check.Name)
+		}
+		names[check.Name] = true
+
+		if check.Description == "" {
+			fail("check %s has no description", check.Name)
+		}
+		if strings.TrimSpace(check.script) == "" {
+			fail("check %s has an empty body", check.Name)
+		}
+		for _, profile := range check.OS {
+			if !contains(c.Profiles, profile) {
+				fail("check %s targets unknown profile '%s'", check.Name, profile)
+			}
+		}
+		if _, err := regexp.Compile(check.ExpectedOutput); err != nil {
+			fail("check %s has an invalid expected_output: %v", check.Name, err)
+		}
+	}
+
+	if _, err := c.properties(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// properties returns the job properties followed by those required by the
+// checks. A property may be required by several checks as long as they agree
+// on its definition.
+func (c *Catalog) properties() ([]Property, error) {
+	var errs []error
+	var properties []Property
+	byName := map[string]Property{}
+	byConfigKey := map[string]string{}
+
+	add := func(owner string, p Property) {
+		if !propertyNameRegexp.MatchString(p.Name) {
+			errs = append(errs, fmt.Errorf("%s declares invalid property name '%s'", owner, p.Name))
+		}
+		if p.Description == "" {
+			errs = append(errs, fmt.Errorf("%s declares property %s without a description", owner, p.Name))
+		}
+
+		if existing, ok := byName[p.Name]; ok {
+			if !reflect.DeepEqual(existing, p) {
+				errs = append(errs, fmt.Errorf("%s declares property %s differently from another check", owner, p.Name))
+			}
+			return
+		}
+		if other, ok := byConfigKey[p.ConfigKey()]; ok {
+			errs = append(errs, fmt.Errorf("%s declares property %s which clashes with %s in config.json", owner, p.Name, other))
+			return
+		}
+
+		byName[p.Name] = p
+		byConfigKey[p.ConfigKey()] = p.Name
+		properties = append(properties, p)
+	}
+
+	for _, p := range c.Job.Properties {
+		add("job "+c.Job.Name, p)
+	}
+	for _, check := range c.Checks {
+		for _, p := range check.Properties {
+			add("check "+check.Name, p)
+		}
+	}
+
+	return properties, errors.Join(errs...)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
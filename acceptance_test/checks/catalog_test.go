@@ -0,0 +1,165 @@
+package checks_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/checks"
+)
+
+var _ = Describe("Catalog", func() {
+	var catalogPath = filepath.Join("..", "assets", "checks", "catalog.yml")
+
+	Describe("the catalog shipped with the suite", func() {
+		It("is valid", func() {
+			catalog, err := checks.LoadCatalog(catalogPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(catalog.Validate()).To(Succeed())
+		})
+	})
+
+	Describe("Render", func() {
+		var dir string
+
+		writeCatalog := func(catalog string) *checks.Catalog {
+			Expect(os.WriteFile(filepath.Join(dir, "catalog.yml"), []byte(catalog), 0644)).To(Succeed())
+			c, err := checks.LoadCatalog(filepath.Join(dir, "catalog.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			return c
+		}
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			Expect(os.Mkdir(filepath.Join(dir, "templates"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "Verify-Thing.ps1"), []byte("Write-Host \"thing's fine\"\n\nExit 0\n"), 0644)).To(Succeed())
+		})
+
+		It("wraps bodies in functions and lists the checks with their metadata", func() {
+			c := writeCatalog(`
+job: {name: check-system}
+profiles: [windows2019]
+checks:
+- name: Verify-Thing
+  description: The thing is fine
+  os: [windows2019]
+  body: Verify-Thing.ps1
+  expected_output: thing's fine
+  properties:
+  - name: thing.enabled
+    description: Whether the thing is expected
+    default: true
+  - name: thing.expected
+    description: What the thing is expected to be, always set in the manifest
+`)
+			Expect(c.Render(dir)).To(Succeed())
+
+			script, err := os.ReadFile(filepath.Join(dir, "templates", "checks.ps1"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(script)).To(ContainSubstring("# The thing is fine\nfunction Verify-Thing {\n  Write-Host \"thing's fine\"\n\n  Exit 0\n}\n"))
+			Expect(string(script)).To(ContainSubstring("@{ Name = 'Verify-Thing'; OS = @('windows2019'); ExpectedOutput = 'thing''s fine'; Manual = $false }\n)"))
+
+			config, err := os.ReadFile(filepath.Join(dir, "templates", "config.json.erb"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(config)).To(ContainSubstring("  thing_enabled: p('thing.enabled'),\n"))
+
+			spec, err := os.ReadFile(filepath.Join(dir, "spec"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(spec)).To(ContainSubstring("  checks.ps1: bin/checks.ps1\n"))
+			Expect(string(spec)).To(ContainSubstring("  thing.enabled:\n    description: \"Whether the thing is expected\"\n    default: true\n"))
+			Expect(string(spec)).To(HaveSuffix("  thing.expected:\n    description: \"What the thing is expected to be, always set in the manifest\"\n"))
+		})
+
+		It("keeps long descriptions on one line and the order of default maps", func() {
+			description := "A description longer than the eighty characters yaml.Marshal wraps lines at, with \"quotes\""
+			c := writeCatalog(`
+job: {name: check-system}
+profiles: [windows2019]
+checks:
+- name: Verify-Thing
+  description: The thing is fine
+  body: Verify-Thing.ps1
+  properties:
+  - name: thing.profiles
+    description: '` + description + `'
+    default:
+    - {name: public, inbound: Block}
+    - {name: private, inbound: Block}
+`)
+			Expect(c.Render(dir)).To(Succeed())
+
+			spec, err := os.ReadFile(filepath.Join(dir, "spec"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(spec)).To(ContainSubstring(`    default: [{"name": "public", "inbound": "Block"}, {"name": "private", "inbound": "Block"}]` + "\n"))
+
+			var parsed struct {
+				Properties map[string]struct {
+					Description string
+					Default     []map[string]string
+				}
+			}
+			Expect(yaml.Unmarshal(spec, &parsed)).To(Succeed())
+			Expect(parsed.Properties["thing.profiles"].Description).To(Equal(description))
+			Expect(parsed.Properties["thing.profiles"].Default).To(Equal([]map[string]string{
+				{"name": "public", "inbound": "Block"},
+				{"name": "private", "inbound": "Block"},
+			}))
+		})
+
+		It("refuses to render an invalid catalog", func() {
+			c := writeCatalog(`
+job: {name: check-system}
+profiles: [windows2019]
+checks:
+- name: Verify-Thing
+  description: The thing is fine
+  os: [windows2012]
+  body: Verify-Thing.ps1
+  expected_output: "("
+  properties:
+  - name: thing.enabled
+    description: Whether the thing is expected
+    default: true
+- name: Verify-Thing
+  description: The thing is still fine
+  body: Verify-Thing.ps1
+  properties:
+  - name: thing.enabled
+    description: Whether the thing is expected
+    default: false
+  - name: thing_enabled
+    description: Clashes in config.json
+- name: thing
+  body: Verify-Thing.ps1
+`)
+			err := c.Render(dir)
+			Expect(err).To(MatchError(SatisfyAll(
+				ContainSubstring("check Verify-Thing targets unknown profile 'windows2012'"),
+				ContainSubstring("check Verify-Thing has an invalid expected_output"),
+				ContainSubstring("check Verify-Thing is declared more than once"),
+				ContainSubstring("check Verify-Thing declares property thing.enabled differently from another check"),
+				ContainSubstring("property thing_enabled which clashes with thing.enabled in config.json"),
+				ContainSubstring("check name 'thing' is not a PowerShell Verb-Noun name"),
+				ContainSubstring("check thing has no description"),
+			)))
+			Expect(filepath.Join(dir, "spec")).NotTo(BeAnExistingFile())
+		})
+
+		It("fails to load a check whose body is missing", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "catalog.yml"), []byte(`
+job: {name: check-system}
+profiles: [windows2019]
+checks:
+- name: Verify-Missing
+  description: Missing
+  body: Verify-Missing.ps1
+`), 0644)).To(Succeed())
+
+			_, err := checks.LoadCatalog(filepath.Join(dir, "catalog.yml"))
+			Expect(err).To(MatchError(ContainSubstring("unable to read body of Verify-Missing")))
+		})
+	})
+})
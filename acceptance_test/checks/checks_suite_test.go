@@ -0,0 +1,13 @@
+package checks_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestChecks(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Checks Suite")
+}
@@ -0,0 +1,187 @@
+package checks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const generatedHeader = "Generated from assets/checks/catalog.yml by the acceptance test harness. DO NOT EDIT."
+
+// Render writes the job spec, templates/checks.ps1 and templates/config.json.erb
+// of the check-system job into jobDir. templates/run.ps1 dot-sources
+// checks.ps1 and runs the checks it lists.
+func (c *Catalog) Render(jobDir string) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	files := map[string]func() ([]byte, error){
+		"spec":                                   c.renderSpec,
+		filepath.Join("templates", "checks.ps1"): c.renderChecks,
+		filepath.Join("templates", "config.json.erb"): c.renderConfig,
+	}
+
+	for name, render := range files {
+		contents, err := render()
+		if err != nil {
+			return fmt.Errorf("unable to render %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(jobDir, name), contents, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderSpec writes the spec by hand rather than with yaml.Marshal, which
+// wraps long descriptions. Descriptions are double-quoted and defaults are in
+// flow style, both valid YAML as written by encoding/json.
+func (c *Catalog) renderSpec() ([]byte, error) {
+	properties, err := c.properties()
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\n# %s\nname: %s\n", generatedHeader, c.Job.Name)
+	if c.Job.Description != "" {
+		description, err := flow(c.Job.Description)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "description: %s\n", description)
+	}
+
+	b.WriteString("templates:\n")
+	for _, template := range c.Job.Templates {
+		fmt.Fprintf(&b, "  %v: %v\n", template.Key, template.Value)
+	}
+	b.WriteString("  checks.ps1: bin/checks.ps1\n")
+
+	if len(c.Job.Packages) == 0 {
+		b.WriteString("packages: []\n")
+	} else {
+		b.WriteString("packages:\n")
+		for _, pkg := range c.Job.Packages {
+			fmt.Fprintf(&b, "- %s\n", pkg)
+		}
+	}
+
+	if len(properties) == 0 {
+		b.WriteString("properties: {}\n")
+		return []byte(b.String()), nil
+	}
+	b.WriteString("properties:\n")
+	for _, p := range properties {
+		description, err := flow(p.Description)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "  %s:\n    description: %s\n", p.Name, description)
+
+		// A property without a default must be set in the manifest.
+		if p.Default != nil {
+			value, err := flow(p.Default)
+			if err != nil {
+				return nil, fmt.Errorf("default of %s: %v", p.Name, err)
+			}
+			fmt.Fprintf(&b, "    default: %s\n", value)
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+// flow writes a value parsed from the catalog in YAML flow style, keeping the
+// order of its maps.
+func flow(v interface{}) (string, error) {
+	var items []string
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		for _, item := range v {
+			key, err := flow(fmt.Sprint(item.Key))
+			if err != nil {
+				return "", err
+			}
+			value, err := flow(item.Value)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, key+": "+value)
+		}
+		return "{" + strings.Join(items, ", ") + "}", nil
+	case []interface{}:
+		for _, item := range v {
+			value, err := flow(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, value)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	default:
+		body, err := json.Marshal(v)
+		return string(body), err
+	}
+}
+
+func (c *Catalog) renderChecks() ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", generatedHeader)
+
+	for _, check := range c.Checks {
+		fmt.Fprintf(&b, "\n# %s\nfunction %s {\n", check.Description, check.Name)
+		for _, line := range strings.Split(strings.TrimRight(check.script, "\r\n"), "\n") {
+			if strings.TrimSpace(line) == "" {
+				b.WriteString("\n")
+			} else {
+				fmt.Fprintf(&b, "  %s\n", line)
+			}
+		}
+		b.WriteString("}\n")
+	}
+
+	b.WriteString("\n$Checks = @(\n")
+	for i, check := range c.Checks {
+		var os []string
+		for _, profile := range check.OS {
+			os = append(os, psString(profile))
+		}
+		separator := ","
+		if i == len(c.Checks)-1 {
+			separator = ""
+		}
+		fmt.Fprintf(&b, "  @{ Name = %s; OS = @(%s); ExpectedOutput = %s; Manual = $%t }%s\n",
+			psString(check.Name), strings.Join(os, ", "), psString(check.ExpectedOutput), check.Manual, separator)
+	}
+	b.WriteString(")\n")
+
+	return []byte(b.String()), nil
+}
+
+func (c *Catalog) renderConfig() ([]byte, error) {
+	properties, err := c.properties()
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%%# %s %%>\n<%%=\n{\n", generatedHeader)
+	for _, p := range properties {
+		fmt.Fprintf(&b, "  %s: p('%s'),\n", p.ConfigKey(), p.Name)
+	}
+	b.WriteString("}.to_json\n%>\n")
+
+	return []byte(b.String()), nil
+}
+
+// psString quotes s as a verbatim PowerShell string.
+func psString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,73 @@
+// Package checks models the checks run by the check-system errand.
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Status string
+
+const (
+	Passed  Status = "passed"
+	Failed  Status = "failed"
+	Skipped Status = "skipped"
+)
+
+type Result struct {
+	Name   string
+	Status Status
+}
+
+// Report lists the results of an errand run in the order the checks ran.
+type Report []Result
+
+var markerRegexp = regexp.MustCompile(`\[check\] (\S+) (started|passed|skipped)`)
+
+// ParseReport reads the "[check] <name> <state>" markers written by
+// Invoke-Check. A check that started but never passed has failed.
+func ParseReport(output []byte) Report {
+	var report Report
+	index := map[string]int{}
+
+	for _, match := range markerRegexp.FindAllStringSubmatch(string(output), -1) {
+		name, state := match[1], match[2]
+
+		i, seen := index[name]
+		if !seen {
+			i = len(report)
+			index[name] = i
+			report = append(report, Result{Name: name})
+		}
+
+		switch state {
+		case "started":
+			report[i].Status = Failed
+		case "passed":
+			report[i].Status = Passed
+		case "skipped":
+			report[i].Status = Skipped
+		}
+	}
+
+	return report
+}
+
+func (r Report) WithStatus(status Status) []string {
+	var names []string
+	for _, result := range r {
+		if result.Status == status {
+			names = append(names, result.Name)
+		}
+	}
+	return names
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	for _, result := range r {
+		fmt.Fprintf(&b, "%-8s %s\n", strings.ToUpper(string(result.Status)), result.Name)
+	}
+	return b.String()
+}
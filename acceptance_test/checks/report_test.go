@@ -0,0 +1,35 @@
+package checks_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/checks"
+)
+
+var _ = Describe("ParseReport", func() {
+	It("reports passed, skipped and failed checks in the order they ran", func() {
+		output := []byte(`Instance   check-multiple/0f1e
+Exit Code  1
+Stdout     [check] Verify-LGPO skipped
+           [check] Verify-Dependencies started
+           Checking C:\\var\\vcap\\bosh\\bin dependencies
+           [check] Verify-Dependencies passed
+           [check] Verify-Acls started
+           Error (C:\var\foo): Everyone,Allow
+`)
+
+		report := checks.ParseReport(output)
+		Expect(report).To(Equal(checks.Report{
+			{Name: "Verify-LGPO", Status: checks.Skipped},
+			{Name: "Verify-Dependencies", Status: checks.Passed},
+			{Name: "Verify-Acls", Status: checks.Failed},
+		}))
+		Expect(report.WithStatus(checks.Skipped)).To(ConsistOf("Verify-LGPO"))
+		Expect(report.String()).To(ContainSubstring("SKIPPED  Verify-LGPO\n"))
+	})
+
+	It("returns an empty report when there are no markers", func() {
+		Expect(checks.ParseReport([]byte("Exit Code 0"))).To(BeEmpty())
+	})
+})
@@ -0,0 +1,444 @@
+// bwats runs the steps of the acceptance suite on their own, e.g. to iterate
+// on a single check against a long-lived deployment. What each step creates is
+// recorded in a state file, read by the steps after it.
+//
+//	go run ./cmd/bwats -config config.json <command> [args]
+//
+// Run it from the acceptance_test directory, or pass -root.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/checks"
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+const deploymentPrefix = "windows-acceptance-test-"
+
+type command struct {
+	usage string
+	run   func(c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"validate-config": {"validate-config", validateConfig},
+	"prepare":         {"prepare", prepare},
+	"deploy":          {"deploy [-d deployment]", deploy},
+	"run-checks":      {"run-checks [-d deployment] [check...]", runChecks},
+	"collect-logs":    {"collect-logs [-d deployment] [-dir dir]", collectLogs},
+	"report":          {"report [-d deployment]", report},
+	"cleanup":         {"cleanup", cleanup},
+	"reap":            {"reap [-older-than duration]", reap},
+}
+
+// cli is what the subcommands share: the config, the bosh runner and the state.
+type cli struct {
+	root      string
+	statePath string
+	config    *harness.Config
+	bosh      *harness.Bosh
+	state     *state
+}
+
+func main() {
+	flags := flag.NewFlagSet("bwats", flag.ExitOnError)
+	configPath := flags.String("config", os.Getenv("CONFIG_JSON"), "path to the test config, defaults to $CONFIG_JSON")
+	root := flags.String("root", ".", "the acceptance_test directory, with the assets of the suite")
+	statePath := flags.String("state", ".bwats-state.json", "file recording what was created on the director")
+	dryRun := flags.String("dry-run", harness.DryRunDir(), "plan the bosh operations that change the director, writing the plan to this directory, defaults to $BWATS_DRY_RUN")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <command> [args]\n\nCommands:\n", os.Args[0])
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+		}
+		fmt.Fprintf(os.Stderr, "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:]) //nolint:errcheck
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	c, err := newCLI(*configPath, *root, *statePath)
+	if err == nil {
+		if *dryRun != "" && c.bosh.Plan == nil {
+			c.bosh.Plan = harness.NewPlan()
+		}
+		err = cmd.run(c, flags.Args()[1:])
+		if c.bosh.Plan != nil {
+			if planErr := c.bosh.Plan.Write(*dryRun); err == nil {
+				err = planErr
+			}
+			fmt.Fprintf(os.Stderr, "\nPlan written to %s:\n%s", *dryRun, c.bosh.Plan) //nolint:errcheck
+		}
+		if cleanupErr := c.bosh.Cleanup(); err == nil {
+			err = cleanupErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newCLI(configPath, root, statePath string) (*cli, error) {
+	if configPath == "" {
+		return nil, fmt.Errorf("no config, pass -config or set CONFIG_JSON")
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := harness.LoadConfig(configPath, root)
+	if err != nil {
+		return nil, err
+	}
+
+	bosh, err := harness.NewBosh(config, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := loadState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	if s.Deployment == "" {
+		s.Deployment = config.ExistingDeployment
+	}
+
+	return &cli{root: root, statePath: statePath, config: config, bosh: bosh, state: s}, nil
+}
+
+// saveState saves the state file, unless this is a dry run, which creates
+// nothing on the director.
+func (c *cli) saveState() error {
+	if c.bosh.Plan != nil {
+		return nil
+	}
+	return c.state.save(c.statePath)
+}
+
+// deploymentFlag parses the -d flag of a subcommand, which defaults to the
+// deployment in the state file.
+func (c *cli) deploymentFlag(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	deployment := flags.String("d", c.state.Deployment, "deployment name")
+	return flags, deployment
+}
+
+func validateConfig(c *cli, args []string) error {
+	if err := c.config.Validate(); err != nil {
+		return err
+	}
+
+	fmt.Println("config is valid")
+	return nil
+}
+
+func prepare(c *cli, args []string) error {
+	if err := c.bosh.Run("login"); err != nil {
+		return err
+	}
+
+	releaseVersion := c.config.ExistingReleaseVersion
+	if releaseVersion != "" {
+		exists, err := harness.ReleaseExists(c.bosh, releaseVersion)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("existing_release_version %s has not been uploaded", releaseVersion)
+		}
+	} else {
+		var err error
+		releaseVersion, err = harness.CreateRelease(c.bosh, c.root, c.config)
+		if err != nil {
+			return err
+		}
+	}
+	c.state.ReleaseVersion = releaseVersion
+	if err := c.saveState(); err != nil {
+		return err
+	}
+
+	stemcell, err := harness.FetchStemcellInfo(c.config.StemcellPath)
+	if err != nil {
+		return err
+	}
+	if err := harness.UploadStemcell(c.bosh, c.config.StemcellPath); err != nil {
+		return err
+	}
+	c.state.StemcellName = stemcell.Name
+	c.state.StemcellVersion = stemcell.Version
+
+	fmt.Printf("release %s/%s\nstemcell %s/%s\n", harness.ReleaseName, releaseVersion, stemcell.Name, stemcell.Version)
+	return c.saveState()
+}
+
+func deploy(c *cli, args []string) error {
+	flags, deployment := c.deploymentFlag("deploy")
+	flags.Parse(args) //nolint:errcheck
+
+	if err := c.state.requirePrepared(); err != nil {
+		return err
+	}
+	if *deployment == "" {
+		*deployment = fmt.Sprintf("%s%d", deploymentPrefix, harness.TimestampInMs())
+	}
+
+	c.state.Deployment = *deployment
+	if err := c.saveState(); err != nil {
+		return err
+	}
+
+	if err := c.config.Deploy(c.bosh, c.root, *deployment, c.state.StemcellVersion, c.state.ReleaseVersion); err != nil {
+		return err
+	}
+
+	cache, err := c.config.ReleaseCache()
+	if err != nil {
+		return err
+	}
+	if cache != nil {
+		if err := cache.Export(c.bosh, *deployment, c.state.ReleaseVersion); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("deployment %s\n", *deployment)
+	return nil
+}
+
+// runChecks runs the check-system errand. When checks are named, the
+// deployment is first updated to focus on them.
+func runChecks(c *cli, args []string) error {
+	flags, deployment := c.deploymentFlag("run-checks")
+	flags.Parse(args) //nolint:errcheck
+
+	if *deployment == "" {
+		return c.state.requireDeployment()
+	}
+
+	if names := flags.Args(); len(names) != 0 {
+		if err := c.state.requirePrepared(); err != nil {
+			return err
+		}
+
+		config := *c.config
+		config.Checks.Focus = names
+		config.Checks.Skip = nil
+		if err := config.Deploy(c.bosh, c.root, *deployment, c.state.StemcellVersion, c.state.ReleaseVersion); err != nil {
+			return err
+		}
+	}
+
+	stdout, errandErr := c.bosh.RunErrandStdOut("check-system", *deployment)
+	r := checks.ParseReport(stdout)
+	fmt.Print(r.String())
+
+	c.state.Checks = r
+	if err := c.saveState(); err != nil {
+		return err
+	}
+
+	if errandErr != nil {
+		return errandErr
+	}
+	if failed := r.WithStatus(checks.Failed); len(failed) != 0 {
+		return fmt.Errorf("failed checks: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func collectLogs(c *cli, args []string) error {
+	flags, deployment := c.deploymentFlag("collect-logs")
+	dir := flags.String("dir", "logs", "directory the logs are downloaded to")
+	flags.Parse(args) //nolint:errcheck
+
+	if *deployment == "" {
+		return c.state.requireDeployment()
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+	absDir, err := filepath.Abs(*dir)
+	if err != nil {
+		return err
+	}
+
+	tarballs, err := harness.DownloadDeploymentLogs(c.bosh, *deployment, absDir)
+	if err != nil {
+		return err
+	}
+
+	for _, tarball := range tarballs {
+		fmt.Println(tarball)
+	}
+	return nil
+}
+
+// report prints what the state file records, the instances of the deployment
+// and the results of the last run-checks.
+func report(c *cli, args []string) error {
+	flags, deployment := c.deploymentFlag("report")
+	flags.Parse(args) //nolint:errcheck
+
+	fmt.Printf("release:    %s/%s\n", harness.ReleaseName, c.state.ReleaseVersion)
+	fmt.Printf("stemcell:   %s/%s\n", c.state.StemcellName, c.state.StemcellVersion)
+	fmt.Printf("deployment: %s\n", *deployment)
+
+	if *deployment != "" {
+		instances, err := c.bosh.RunTable(fmt.Sprintf("-d %s instances", *deployment))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\ninstances:\n")
+		for _, instance := range instances {
+			fmt.Printf("  %-60s %-10s %s\n", instance["instance"], instance["process_state"], instance["ips"])
+		}
+	}
+
+	if len(c.state.Checks) != 0 {
+		fmt.Printf("\nchecks:\n%s", c.state.Checks.String())
+	}
+	return nil
+}
+
+// cleanup deletes what the state file records, then the state file. The
+// existing deployment and release of the config are left in place.
+func cleanup(c *cli, args []string) error {
+	if c.config.ExistingDeployment != "" && c.state.Deployment == c.config.ExistingDeployment {
+		// The stemcell is in use by the deployment.
+		c.state.Deployment = ""
+		c.state.StemcellName = ""
+	}
+	if c.state.ReleaseVersion == c.config.ExistingReleaseVersion {
+		c.state.ReleaseVersion = ""
+	}
+
+	if c.state.Deployment != "" {
+		if err := c.bosh.Run(fmt.Sprintf("-d %s delete-deployment --force", c.state.Deployment)); err != nil {
+			return err
+		}
+	}
+	if c.state.ReleaseVersion != "" {
+		if err := c.bosh.Run(fmt.Sprintf("delete-release %s/%s", harness.ReleaseName, c.state.ReleaseVersion)); err != nil {
+			return err
+		}
+	}
+	if c.state.StemcellName != "" && c.state.StemcellVersion != "" {
+		if err := c.bosh.Run(fmt.Sprintf("delete-stemcell %s/%s", c.state.StemcellName, c.state.StemcellVersion)); err != nil {
+			return err
+		}
+	}
+
+	if c.bosh.Plan != nil {
+		return nil
+	}
+	if err := os.Remove(c.statePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// reap deletes the deployments and unused dev releases left behind by earlier
+// runs of the suite, judging their age by the timestamp in their name.
+func reap(c *cli, args []string) error {
+	flags := flag.NewFlagSet("reap", flag.ExitOnError)
+	olderThan := flags.Duration("older-than", 24*time.Hour, "only delete what was created longer ago than this")
+	flags.Parse(args) //nolint:errcheck
+
+	cutoff := time.Now().Add(-*olderThan)
+
+	deployments, err := c.bosh.RunTable("deployments")
+	if err != nil {
+		return err
+	}
+	for _, name := range staleDeployments(deployments, cutoff) {
+		fmt.Printf("deleting deployment %s\n", name)
+		if err := c.bosh.Run(fmt.Sprintf("-d %s delete-deployment --force", name)); err != nil {
+			return err
+		}
+	}
+
+	releases, err := c.bosh.RunTable("releases")
+	if err != nil {
+		return err
+	}
+	for _, version := range staleReleases(releases, cutoff) {
+		fmt.Printf("deleting release %s/%s\n", harness.ReleaseName, version)
+		if err := c.bosh.Run(fmt.Sprintf("delete-release %s/%s", harness.ReleaseName, version)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// staleDeployments returns the names of the deployments of the suite, among
+// the rows of `bosh deployments`, that were created before the cutoff.
+func staleDeployments(rows []map[string]string, cutoff time.Time) []string {
+	var names []string
+	for _, d := range rows {
+		name := d["name"]
+		if strings.HasPrefix(name, deploymentPrefix) && createdBefore(name, cutoff) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// staleReleases returns the versions of the bwats-release, among the rows of
+// `bosh releases`, that were created before the cutoff and are not in use.
+func staleReleases(rows []map[string]string, cutoff time.Time) []string {
+	var versions []string
+	for _, r := range rows {
+		// Versions in use by a deployment are marked with a *.
+		version := r["version"]
+		if r["name"] == harness.ReleaseName && !strings.HasSuffix(version, "*") && createdBefore(version, cutoff) {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// createdBefore reports whether a name ending in a timestamp in ms, such as
+// windows-acceptance-test-1570000000000 or 0.dev+1570000000000, is older than
+// the cutoff.
+func createdBefore(name string, cutoff time.Time) bool {
+	i := strings.LastIndexAny(name, "-+")
+	if i == -1 {
+		return false
+	}
+
+	ms, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil {
+		return false
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).Before(cutoff)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("reap", func() {
+	// 1570000000000 is 2019-10-02T07:06:40Z.
+	cutoff := time.UnixMilli(1570000000000)
+
+	DescribeTable("createdBefore",
+		func(name string, expected bool) {
+			Expect(createdBefore(name, cutoff)).To(Equal(expected))
+		},
+		Entry("a deployment created before the cutoff", "windows-acceptance-test-1569999999999", true),
+		Entry("a deployment created at the cutoff", "windows-acceptance-test-1570000000000", false),
+		Entry("a deployment created after the cutoff", "windows-acceptance-test-1570000000001", false),
+		Entry("a deployment of a spec", "windows-acceptance-test-hwc-1569999999999", true),
+		Entry("a recent deployment of a spec", "windows-acceptance-test-hwc-1570000000001", false),
+		Entry("a dev release", "0.dev+1569999999999", true),
+		Entry("a recent dev release", "0.dev+1570000000001", false),
+		Entry("a release in use", "0.dev+1569999999999*", false),
+		Entry("a name without a timestamp", "windows-acceptance-test-hwc", false),
+		Entry("a final release", "1.2.3", false),
+		Entry("an empty name", "", false),
+	)
+
+	It("selects the deployments of the suite created before the cutoff", func() {
+		Expect(staleDeployments([]map[string]string{
+			{"name": "windows-acceptance-test-1569999999999"},
+			{"name": "windows-acceptance-test-hwc-1569999999999"},
+			{"name": "windows-acceptance-test-ssh-1570000000001"},
+			{"name": "cf-1569999999999"},
+			{"name": "windows-acceptance-test-hwc"},
+		}, cutoff)).To(Equal([]string{
+			"windows-acceptance-test-1569999999999",
+			"windows-acceptance-test-hwc-1569999999999",
+		}))
+	})
+
+	It("selects the dev releases of the suite created before the cutoff that are not in use", func() {
+		Expect(staleReleases([]map[string]string{
+			{"name": "bwats-release", "version": "0.dev+1569999999998"},
+			{"name": "bwats-release", "version": "0.dev+1569999999999*"},
+			{"name": "bwats-release", "version": "0.dev+1570000000001"},
+			{"name": "bosh-dns", "version": "0.dev+1569999999999"},
+			{"name": "bwats-release", "version": "1.2.3"},
+		}, cutoff)).To(Equal([]string{"0.dev+1569999999998"}))
+	})
+})
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/checks"
+)
+
+// state is what earlier subcommands created on the director, so that later
+// ones can be run on their own against it.
+type state struct {
+	ReleaseVersion  string        `json:"release_version,omitempty"`
+	StemcellName    string        `json:"stemcell_name,omitempty"`
+	StemcellVersion string        `json:"stemcell_version,omitempty"`
+	Deployment      string        `json:"deployment,omitempty"`
+	Checks          checks.Report `json:"checks,omitempty"`
+}
+
+func loadState(path string) (*state, error) {
+	var s state
+
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, &s); err != nil {
+		return nil, fmt.Errorf("unable to parse state file %s: %s", path, err)
+	}
+	return &s, nil
+}
+
+func (s *state) save(path string) error {
+	body, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, body, 0644)
+}
+
+func (s *state) requirePrepared() error {
+	if s.ReleaseVersion == "" || s.StemcellVersion == "" {
+		return fmt.Errorf("no release or stemcell in the state file, run prepare first")
+	}
+	return nil
+}
+
+func (s *state) requireDeployment() error {
+	if s.Deployment == "" {
+		return fmt.Errorf("no deployment in the state file, run deploy first or pass -d")
+	}
+	return nil
+}
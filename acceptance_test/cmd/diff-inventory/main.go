@@ -0,0 +1,33 @@
+// diff-inventory prints the changes between two inventories saved from the
+// inventory errand, e.g. by setting inventory_path in the test config.
+//
+//	go run ./cmd/diff-inventory <old-inventory.json> <new-inventory.json>
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/inventory"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintf(os.Stderr, "Usage: %s <old-inventory.json> <new-inventory.json>\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	before, err := inventory.Load(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	after, err := inventory.Load(os.Args[2])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Print(inventory.Diff(before, after))
+}
@@ -0,0 +1,70 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// containerReport is written by the container-smoke errand.
+type containerReport struct {
+	Created                 bool   `json:"created"`
+	Started                 bool   `json:"started"`
+	Command                 string `json:"command"`
+	ExitCode                int    `json:"exit_code"`
+	Output                  string `json:"output"`
+	Terminated              bool   `json:"terminated"`
+	LayersCleanedUp         bool   `json:"layers_cleaned_up"`
+	RemainingComputeSystems int    `json:"remaining_compute_systems"`
+	Error                   string `json:"error"`
+}
+
+var _ = Describe("Windows containers", func() {
+	var containerDeploymentName string
+
+	BeforeEach(func() {
+		if testConfig.ContainerBaseLayerPath == "" {
+			Skip("Skipping container test - container_base_layer_path is not set")
+		}
+
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		containerDeploymentName = fmt.Sprintf("windows-acceptance-test-container-%d", getTimestampInMs())
+
+		err = testConfig.deployWithManifest(boshCommand, containerDeploymentName, stemcellVersion, releaseVersion,
+			filepath.Join(pwd, "assets", "container-manifest.yml"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if testConfig.SkipCleanup || containerDeploymentName == "" {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", containerDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("runs a process-isolated container and cleans it up", func() {
+		var report containerReport
+		body := runErrandAndReadLog(containerDeploymentName, "container-smoke", "container-smoke/container.json", boshCommand)
+		Expect(json.Unmarshal(body, &report)).To(Succeed())
+		Expect(report.Error).To(BeEmpty())
+
+		By("Running a command in the container")
+		Expect(report.Created).To(BeTrue())
+		Expect(report.Started).To(BeTrue())
+		Expect(report.ExitCode).To(Equal(0))
+		Expect(report.Output).To(ContainSubstring("bwats container smoke test"))
+
+		By("Cleaning up the container and its layers")
+		Expect(report.Terminated).To(BeTrue())
+		Expect(report.LayersCleanedUp).To(BeTrue())
+		Expect(report.RemainingComputeSystems).To(Equal(0))
+	})
+})
@@ -0,0 +1,125 @@
+// Package expectations holds the stemcell expectations asserted by the
+// check-system errand, so they can be tuned per stemcell flavour without
+// editing the PowerShell in the bwats-release.
+package expectations
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+type Expectations struct {
+	Acls     Acls     `yaml:"acls"`
+	Services Services `yaml:"services"`
+	Features Features `yaml:"features"`
+	Firewall Firewall `yaml:"firewall"`
+}
+
+type Acls struct {
+	// Identities are "<IdentityReference>,<AccessControlType>" pairs. Windows
+	// environment variables such as %COMPUTERNAME% are expanded on the VM.
+	Identities []string `yaml:"identities"`
+	Paths      []string `yaml:"paths"`
+}
+
+type Services struct {
+	Stopped []string `yaml:"stopped"`
+	// SSH services have a start type of Disabled when ssh.disabled_by_default
+	// is set and Automatic otherwise.
+	SSH []string `yaml:"ssh"`
+}
+
+type Features struct {
+	Installed    []string `yaml:"installed"`
+	NotInstalled []string `yaml:"not_installed"`
+}
+
+type Firewall struct {
+	Profiles            []FirewallProfile `yaml:"profiles"`
+	MetadataServerRules []string          `yaml:"metadata_server_rules"`
+}
+
+type FirewallProfile struct {
+	Name                  string `yaml:"name"`
+	DefaultInboundAction  string `yaml:"default_inbound_action"`
+	DefaultOutboundAction string `yaml:"default_outbound_action"`
+}
+
+// File is the on-disk layout of an expectations file: a set of defaults and
+// per stemcell OS adjustments. Lists under "override" replace the defaults,
+// lists under "extend" are appended to them.
+type File struct {
+	Default   Expectations             `yaml:"default"`
+	Stemcells map[string]Customization `yaml:"stemcells"`
+}
+
+type Customization struct {
+	Override Expectations `yaml:"override"`
+	Extend   Expectations `yaml:"extend"`
+}
+
+func Load(path string) (*File, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	if err := yaml.UnmarshalStrict(body, &f); err != nil {
+		return nil, fmt.Errorf("unable to parse expectations file '%s': %v", path, err)
+	}
+
+	return &f, nil
+}
+
+// For returns the expectations for the given stemcell OS, e.g. windows2019.
+func (f *File) For(stemcellOs string) Expectations {
+	e := f.Default
+
+	if c, ok := f.Stemcells[stemcellOs]; ok {
+		e = e.override(c.Override)
+		e = e.extend(c.Extend)
+	}
+
+	return e
+}
+
+func (e Expectations) override(o Expectations) Expectations {
+	replace := func(dst *[]string, src []string) {
+		if src != nil {
+			*dst = src
+		}
+	}
+
+	replace(&e.Acls.Identities, o.Acls.Identities)
+	replace(&e.Acls.Paths, o.Acls.Paths)
+	replace(&e.Services.Stopped, o.Services.Stopped)
+	replace(&e.Services.SSH, o.Services.SSH)
+	replace(&e.Features.Installed, o.Features.Installed)
+	replace(&e.Features.NotInstalled, o.Features.NotInstalled)
+	replace(&e.Firewall.MetadataServerRules, o.Firewall.MetadataServerRules)
+	if o.Firewall.Profiles != nil {
+		e.Firewall.Profiles = o.Firewall.Profiles
+	}
+
+	return e
+}
+
+func (e Expectations) extend(o Expectations) Expectations {
+	add := func(dst *[]string, src []string) {
+		*dst = append(append([]string{}, *dst...), src...)
+	}
+
+	add(&e.Acls.Identities, o.Acls.Identities)
+	add(&e.Acls.Paths, o.Acls.Paths)
+	add(&e.Services.Stopped, o.Services.Stopped)
+	add(&e.Services.SSH, o.Services.SSH)
+	add(&e.Features.Installed, o.Features.Installed)
+	add(&e.Features.NotInstalled, o.Features.NotInstalled)
+	add(&e.Firewall.MetadataServerRules, o.Firewall.MetadataServerRules)
+	e.Firewall.Profiles = append(append([]FirewallProfile{}, e.Firewall.Profiles...), o.Firewall.Profiles...)
+
+	return e
+}
@@ -0,0 +1,13 @@
+package expectations_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestExpectations(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Expectations Suite")
+}
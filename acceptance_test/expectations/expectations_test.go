@@ -0,0 +1,64 @@
+package expectations_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/expectations"
+)
+
+var _ = Describe("Expectations", func() {
+	It("loads the expectations shipped with the suite", func() {
+		f, err := expectations.Load(filepath.Join("..", "assets", "expectations.yml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		e := f.For("windows2019")
+		Expect(e.Acls.Paths).To(ContainElement(`C:\Program Files\OpenSSH`))
+		Expect(e.Acls.Identities).To(ContainElement(`%COMPUTERNAME%\Administrator,Allow`))
+		Expect(e.Services.SSH).To(ConsistOf("sshd", "ssh-agent"))
+		Expect(e.Features.Installed).To(ConsistOf("Containers"))
+		Expect(e.Firewall.Profiles).To(HaveLen(3))
+		Expect(e.Firewall.MetadataServerRules).To(ContainElement("Allow-BOSH-Agent-Metadata-Server"))
+	})
+
+	It("overrides and extends the defaults for a stemcell OS", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "expectations.yml")
+		Expect(os.WriteFile(path, []byte(`---
+default:
+  features:
+    installed: [Containers]
+    not_installed: [Windows-Defender]
+  services:
+    stopped: [WinRM]
+stemcells:
+  windows2022:
+    override:
+      features:
+        not_installed: []
+    extend:
+      features:
+        installed: [Hyper-V]
+`), 0600)).To(Succeed())
+
+		f, err := expectations.Load(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		e := f.For("windows2022")
+		Expect(e.Features.Installed).To(Equal([]string{"Containers", "Hyper-V"}))
+		Expect(e.Features.NotInstalled).To(BeEmpty())
+		Expect(e.Services.Stopped).To(Equal([]string{"WinRM"}))
+
+		Expect(f.For("windows2019").Features.NotInstalled).To(Equal([]string{"Windows-Defender"}))
+	})
+
+	It("rejects unknown keys", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "expectations.yml")
+		Expect(os.WriteFile(path, []byte("default:\n  acl: {}\n"), 0600)).To(Succeed())
+
+		_, err := expectations.Load(path)
+		Expect(err).To(MatchError(ContainSubstring("unable to parse expectations file")))
+	})
+})
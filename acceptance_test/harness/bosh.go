@@ -0,0 +1,332 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const DefaultBoshTimeout = 90 * time.Minute
+
+// Bosh runs bosh CLI commands against the director of a config.
+type Bosh struct {
+	DirectorIP   string
+	Client       string
+	ClientSecret string
+	CertPath     string // Path to CA CERT file, if any
+	Timeout      time.Duration
+	// Output receives the commands that are run and their output.
+	Output io.Writer
+	// Secrets are redacted from recorded interactions.
+	Secrets []string
+	// Recorder, if set, records every command that is run. Replayer, if set,
+	// serves commands from its interactions instead of running them.
+	Recorder *Cassette
+	Replayer *Cassette
+	// Plan, if set, makes this a dry run: commands that change the director
+	// or the release are added to it instead of being run.
+	Plan *Plan
+}
+
+// NewBosh writes the CA certificate of the director, if any, to a temporary
+// file removed by Cleanup. The timeout of commands can be overridden with
+// BWATS_BOSH_TIMEOUT. Commands are recorded to the cassette at
+// BWATS_BOSH_RECORD, or replayed from the one at BWATS_BOSH_REPLAY. When
+// BWATS_DRY_RUN is set, commands are planned instead, see DryRunDir.
+func NewBosh(config *Config, output io.Writer) (*Bosh, error) {
+	var boshCertPath string
+	if cert := config.Bosh.CaCert; cert != "" {
+		certFile, err := os.CreateTemp("", "")
+		if err != nil {
+			return nil, err
+		}
+		defer certFile.Close() //nolint:errcheck
+
+		if _, err := certFile.Write([]byte(cert)); err != nil {
+			return nil, err
+		}
+
+		boshCertPath, err = filepath.Abs(certFile.Name())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := DefaultBoshTimeout
+	if s := os.Getenv("BWATS_BOSH_TIMEOUT"); s != "" {
+		fmt.Fprintf(output, "Using BWATS_BOSH_TIMEOUT (%s) as timeout\n", s) //nolint:errcheck
+
+		if t, err := time.ParseDuration(s); err != nil {
+			fmt.Fprintf(output, "Error parsing BWATS_BOSH_TIMEOUT (%s): %s - falling back to default\n", s, err) //nolint:errcheck
+		} else {
+			timeout = t
+		}
+	}
+
+	b := &Bosh{
+		DirectorIP:   config.Bosh.Target,
+		Client:       config.Bosh.Client,
+		ClientSecret: config.Bosh.ClientSecret,
+		CertPath:     boshCertPath,
+		Timeout:      timeout,
+		Output:       output,
+		Secrets:      []string{config.Bosh.ClientSecret, config.DefaultPassword},
+	}
+
+	if path := os.Getenv("BWATS_BOSH_RECORD"); path != "" {
+		fmt.Fprintf(output, "Recording bosh commands to BWATS_BOSH_RECORD (%s)\n", path) //nolint:errcheck
+
+		cassette, err := NewCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		b.Recorder = cassette
+	}
+	if path := os.Getenv("BWATS_BOSH_REPLAY"); path != "" {
+		fmt.Fprintf(output, "Replaying bosh commands from BWATS_BOSH_REPLAY (%s)\n", path) //nolint:errcheck
+
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		b.Replayer = cassette
+	}
+
+	if DryRunDir() != "" {
+		fmt.Fprintf(output, "Dry run, writing the plan to BWATS_DRY_RUN (%s)\n", DryRunDir()) //nolint:errcheck
+		b.Plan = NewPlan()
+	}
+
+	return b, nil
+}
+
+// DryRunDir is the directory the plan of a dry run is written to, set with
+// BWATS_DRY_RUN.
+func DryRunDir() string {
+	return os.Getenv("BWATS_DRY_RUN")
+}
+
+// Cleanup removes the CA certificate written by NewBosh.
+func (b *Bosh) Cleanup() error {
+	if b.CertPath == "" {
+		return nil
+	}
+	return os.RemoveAll(b.CertPath)
+}
+
+// Args are the arguments of the bosh CLI for a command, which is split on
+// spaces, prefixed with the director and its credentials.
+func (b *Bosh) Args(command string) []string {
+	return b.argsOf(strings.Split(command, " "))
+}
+
+func (b *Bosh) argsOf(command []string) []string {
+	args := append([]string{"-n", "-e", b.DirectorIP, "--client", b.Client, "--client-secret", b.ClientSecret}, command...)
+	if b.CertPath != "" {
+		args = append([]string{"--ca-cert", b.CertPath}, args...)
+	}
+	return args
+}
+
+func (b *Bosh) Run(command string) error {
+	return b.RunIn(command, "")
+}
+
+func (b *Bosh) RunIn(command, dir string) error {
+	_, err := b.RunInStdOut(command, dir)
+	return err
+}
+
+func (b *Bosh) RunErrand(errandName string, deploymentName string) error {
+	_, err := b.RunErrandStdOut(errandName, deploymentName)
+	return err
+}
+
+func (b *Bosh) RunErrandStdOut(errandName string, deploymentName string) ([]byte, error) {
+	return b.RunInStdOut(fmt.Sprintf("-d %s run-errand --download-logs %s --tty", deploymentName, errandName), "")
+}
+
+// RunTable runs a command with --json and returns the rows of the first table
+// it prints, e.g. for `vms` or `instances`.
+func (b *Bosh) RunTable(command string) ([]map[string]string, error) {
+	tables, err := b.RunTables(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no table in the output of %q", command)
+	}
+
+	return tables[0], nil
+}
+
+// RunTables runs a command with --json and returns the rows of each table it
+// prints, e.g. the jobs and packages of `inspect-release`.
+func (b *Bosh) RunTables(command string) ([][]map[string]string, error) {
+	stdout, err := b.RunInStdOut(command+" --json", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var output struct {
+		Tables []struct {
+			Rows []map[string]string
+		}
+	}
+	if err := json.Unmarshal(stdout, &output); err != nil {
+		return nil, err
+	}
+
+	tables := make([][]map[string]string, len(output.Tables))
+	for i, table := range output.Tables {
+		tables[i] = table.Rows
+	}
+	return tables, nil
+}
+
+// RunInStdOut runs a command in dir, or the current directory if empty, and
+// returns its stdout. It fails if the command exits non-zero or runs for
+// longer than the timeout.
+func (b *Bosh) RunInStdOut(command, dir string) ([]byte, error) {
+	return b.RunArgsIn(strings.Split(command, " "), dir)
+}
+
+// RunArgsIn is RunInStdOut for a command already split into arguments, which
+// may contain spaces, e.g. the --command of `bosh ssh`.
+func (b *Bosh) RunArgsIn(command []string, dir string) ([]byte, error) {
+	if planned, err := b.planned(command, dir); planned {
+		return nil, err
+	}
+
+	args := b.argsOf(command)
+	cmdline := strings.Join(append([]string{"bosh"}, args...), " ")
+
+	if dir != "" {
+		fmt.Fprintf(b.output(), "\nRUNNING %q IN %q\n", cmdline, dir) //nolint:errcheck
+	} else {
+		fmt.Fprintf(b.output(), "\nRUNNING %q\n", cmdline) //nolint:errcheck
+	}
+
+	var i Interaction
+	if b.Replayer != nil {
+		var err error
+		i, err = b.Replayer.Replay(b.normalizeArgs(args))
+		if err != nil {
+			return nil, err
+		}
+		if err := restoreFiles(args, i.Files); err != nil {
+			return nil, err
+		}
+		fmt.Fprint(b.output(), i.Stdout, i.Stderr) //nolint:errcheck
+	} else {
+		var err error
+		i, err = b.execute(args, dir)
+		if err != nil {
+			return []byte(i.Stdout), err
+		}
+	}
+
+	if i.ExitCode != 0 {
+		return []byte(i.Stdout),
+			fmt.Errorf(
+				"Non-zero exit code for cmd %q: %d\nSTDERR:\n%s\nSTDOUT:%s\n",
+				cmdline, i.ExitCode, i.Stderr, i.Stdout,
+			)
+	}
+	return []byte(i.Stdout), nil
+}
+
+// Command returns the bosh CLI command for a command that runs in the
+// background, e.g. `bosh ssh` with port forwarding, for the caller to start
+// and stop. Such a command is neither recorded nor replayed, as it has no
+// output until it is stopped: replaying it is an error. In a dry run it is
+// added to the plan instead, and the returned command is nil.
+func (b *Bosh) Command(command string) (*exec.Cmd, error) {
+	split := strings.Split(command, " ")
+	if planned, err := b.planned(split, ""); planned {
+		return nil, err
+	}
+
+	args := b.argsOf(split)
+	cmdline := strings.Join(append([]string{"bosh"}, args...), " ")
+	if b.Replayer != nil {
+		return nil, fmt.Errorf("%q runs in the background and cannot be replayed", cmdline)
+	}
+
+	fmt.Fprintf(b.output(), "\nSTARTING %q\n", cmdline) //nolint:errcheck
+	return exec.Command("bosh", args...), nil
+}
+
+// execute runs the bosh CLI, and records the interaction if recording.
+func (b *Bosh) execute(args []string, dir string) (Interaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "bosh", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = io.MultiWriter(&stdout, b.output())
+	cmd.Stderr = io.MultiWriter(&stderr, b.output())
+
+	filesDir := outputDir(args)
+	filesBefore := listFiles(filesDir)
+
+	start := time.Now()
+	err := cmd.Run()
+	duration := time.Since(start)
+
+	i := Interaction{Stdout: stdout.String(), Stderr: stderr.String()}
+	if ctx.Err() == context.DeadlineExceeded {
+		return i, fmt.Errorf("cmd %q timed out after %s", strings.Join(cmd.Args, " "), b.timeout())
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		i.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		return i, err
+	}
+
+	if b.Recorder != nil {
+		recorded := Interaction{
+			Args:     b.normalizeArgs(args),
+			Dir:      b.normalize(dir),
+			Stdout:   b.normalize(i.Stdout),
+			Stderr:   b.normalize(i.Stderr),
+			ExitCode: i.ExitCode,
+			Duration: duration.String(),
+		}
+		if filesDir != "" {
+			files, err := newFiles(filesDir, filesBefore)
+			if err != nil {
+				return i, err
+			}
+			recorded.Files = files
+		}
+		if err := b.Recorder.Record(recorded); err != nil {
+			return i, err
+		}
+	}
+
+	return i, nil
+}
+
+func (b *Bosh) timeout() time.Duration {
+	if b.Timeout == 0 {
+		return DefaultBoshTimeout
+	}
+	return b.Timeout
+}
+
+func (b *Bosh) output() io.Writer {
+	if b.Output == nil {
+		return io.Discard
+	}
+	return b.Output
+}
@@ -0,0 +1,295 @@
+package harness
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Interaction is a bosh command and its result, as recorded in a cassette.
+// Its arguments and output are normalized by Bosh: secrets are redacted, and
+// temporary paths are replaced with placeholders, so that a later run issuing
+// the same commands matches them.
+type Interaction struct {
+	Args     []string `json:"args"`
+	Dir      string   `json:"dir"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr"`
+	ExitCode int      `json:"exit_code"`
+	Duration string   `json:"duration"`
+	// Files are written by the command to the directory of its --dir or
+	// --logs-dir flag, e.g. logs tarballs, by name.
+	Files map[string][]byte `json:"files,omitempty"`
+}
+
+// Cassette is a file of interactions, one JSON object per line, so that a run
+// that is interrupted still leaves the interactions recorded until then.
+type Cassette struct {
+	path         string
+	lock         sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+	// timestamps maps the timestamps of the recorded run to those of the
+	// replaying run, as they are matched in the arguments of commands.
+	timestamps map[string]string
+}
+
+// NewCassette creates an empty cassette to record to, replacing any file at
+// path.
+func NewCassette(path string) (*Cassette, error) {
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return nil, err
+	}
+	return &Cassette{path: path}, nil
+}
+
+// LoadCassette reads a cassette to replay.
+func LoadCassette(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	c := &Cassette{path: path}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<30)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var i Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &i); err != nil {
+			return nil, fmt.Errorf("unable to parse interaction %d of cassette %s: %s", len(c.interactions)+1, path, err)
+		}
+		c.interactions = append(c.interactions, i)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	c.replayed = make([]bool, len(c.interactions))
+	c.timestamps = map[string]string{}
+	return c, nil
+}
+
+func (c *Cassette) Interactions() []Interaction {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]Interaction(nil), c.interactions...)
+}
+
+// Record appends an interaction to the cassette file.
+func (c *Cassette) Record(i Interaction) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	body, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(c.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	if _, err := f.Write(append(body, '\n')); err != nil {
+		return err
+	}
+
+	c.interactions = append(c.interactions, i)
+	return nil
+}
+
+// Replay returns the first interaction with the given arguments that has not
+// been replayed yet. Once all of them have been, the last one is replayed
+// again, for commands that are polled.
+//
+// The timestamps in deployment names and release versions differ between
+// runs, so they match any timestamp, as long as a recorded timestamp always
+// matches the same one. They are replaced in the output of the interaction.
+func (c *Cassette) Replay(args []string) (Interaction, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	last := -1
+	var lastTimestamps map[string]string
+	for i, interaction := range c.interactions {
+		timestamps, ok := c.match(interaction.Args, args)
+		if !ok {
+			continue
+		}
+		if !c.replayed[i] {
+			c.replayed[i] = true
+			return c.rewrite(interaction, timestamps), nil
+		}
+		last, lastTimestamps = i, timestamps
+	}
+
+	if last == -1 {
+		return Interaction{}, fmt.Errorf("no interaction in cassette %s for %q", c.path, strings.Join(args, " "))
+	}
+	return c.rewrite(c.interactions[last], lastTimestamps), nil
+}
+
+// match returns the timestamps matched between recorded and live arguments.
+func (c *Cassette) match(recorded, live []string) (map[string]string, bool) {
+	if len(recorded) != len(live) {
+		return nil, false
+	}
+
+	timestamps := map[string]string{}
+	for i := range recorded {
+		if recorded[i] == live[i] {
+			continue
+		}
+		if timestampRegexp.ReplaceAllString(recorded[i], timestampPlaceholder) != timestampRegexp.ReplaceAllString(live[i], timestampPlaceholder) {
+			return nil, false
+		}
+
+		liveTimestamps := timestampRegexp.FindAllString(live[i], -1)
+		for j, timestamp := range timestampRegexp.FindAllString(recorded[i], -1) {
+			if mapped, ok := c.timestamps[timestamp]; ok && mapped != liveTimestamps[j] {
+				return nil, false
+			}
+			if mapped, ok := timestamps[timestamp]; ok && mapped != liveTimestamps[j] {
+				return nil, false
+			}
+			timestamps[timestamp] = liveTimestamps[j]
+		}
+	}
+	return timestamps, true
+}
+
+// rewrite records the timestamps matched for an interaction and replaces all
+// the recorded timestamps known so far in its output.
+func (c *Cassette) rewrite(i Interaction, timestamps map[string]string) Interaction {
+	for recorded, live := range timestamps {
+		c.timestamps[recorded] = live
+	}
+
+	replacements := make([]string, 0, 2*len(c.timestamps))
+	for recorded, live := range c.timestamps {
+		replacements = append(replacements, recorded, live)
+	}
+	replacer := strings.NewReplacer(replacements...)
+
+	i.Stdout = replacer.Replace(i.Stdout)
+	i.Stderr = replacer.Replace(i.Stderr)
+	if len(i.Files) != 0 {
+		files := map[string][]byte{}
+		for name, body := range i.Files {
+			files[replacer.Replace(name)] = body
+		}
+		i.Files = files
+	}
+	return i
+}
+
+const (
+	redacted             = "<redacted>"
+	caCertPlaceholder    = "<ca-cert>"
+	tmpPlaceholder       = "<tmp>"
+	pwdPlaceholder       = "<pwd>"
+	timestampPlaceholder = "<timestamp>"
+)
+
+// timestampRegexp matches the timestamps in ms in deployment names and
+// release versions, e.g. windows-acceptance-test-1570000000000.
+var timestampRegexp = regexp.MustCompile(`\b\d{13}\b`)
+
+// normalize redacts secrets and replaces the paths in a command or its output
+// that differ between runs with placeholders.
+func (b *Bosh) normalize(s string) string {
+	for _, secret := range b.Secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	if b.CertPath != "" {
+		s = strings.ReplaceAll(s, b.CertPath, caCertPlaceholder)
+	}
+
+	tmp := regexp.QuoteMeta(filepath.Clean(os.TempDir()))
+	s = regexp.MustCompile(tmp+`/[^/\s"']+`).ReplaceAllString(s, tmpPlaceholder)
+
+	if pwd, err := os.Getwd(); err == nil && pwd != "/" {
+		s = strings.ReplaceAll(s, pwd, pwdPlaceholder)
+	}
+
+	return s
+}
+
+func (b *Bosh) normalizeArgs(args []string) []string {
+	normalized := make([]string, len(args))
+	for i, arg := range args {
+		normalized[i] = b.normalize(arg)
+	}
+	return normalized
+}
+
+// outputDir is the directory a command downloads files to, if any.
+func outputDir(args []string) string {
+	for i, arg := range args[:len(args)-1] {
+		if arg == "--dir" || arg == "--logs-dir" {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// newFiles returns the files in dir that are not in before.
+func newFiles(dir string, before map[string]bool) (map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	for _, entry := range entries {
+		if entry.IsDir() || before[entry.Name()] {
+			continue
+		}
+
+		body, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = body
+	}
+	return files, nil
+}
+
+func listFiles(dir string) map[string]bool {
+	names := map[string]bool{}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+	return names
+}
+
+// restoreFiles writes the files of a replayed interaction to the output dir of
+// the live command.
+func restoreFiles(args []string, files map[string][]byte) error {
+	dir := outputDir(args)
+	if dir == "" {
+		return nil
+	}
+
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), body, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package harness_test
+
+import (
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+// fakeBosh echoes its arguments, fails when asked to, and downloads a tarball
+// named after the deployment to the --dir of a command.
+const fakeBosh = `#!/bin/sh
+echo "args: $*"
+echo "stderr of $*" >&2
+deployment=""
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -d) deployment="$2"; shift ;;
+    --dir) echo tarball > "$2/$deployment.tgz"; shift ;;
+    fail) exit 3 ;;
+  esac
+  shift
+done
+`
+
+var _ = Describe("Cassette", func() {
+	var (
+		cassettePath string
+		logsDir      string
+	)
+
+	newBosh := func() *harness.Bosh {
+		return &harness.Bosh{
+			DirectorIP:   "10.0.0.6",
+			Client:       "admin",
+			ClientSecret: "client-secret",
+			Secrets:      []string{"client-secret"},
+		}
+	}
+
+	BeforeEach(func() {
+		putFakeBoshOnPath(fakeBosh)
+
+		cassettePath = filepath.Join(GinkgoT().TempDir(), "cassette.jsonl")
+		logsDir = GinkgoT().TempDir()
+
+		bosh := newBosh()
+		var err error
+		bosh.Recorder, err = harness.NewCassette(cassettePath)
+		Expect(err).NotTo(HaveOccurred())
+
+		stdout, err := bosh.RunInStdOut("-d windows-acceptance-test-1570000000000 vms", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(stdout)).To(ContainSubstring("-d windows-acceptance-test-1570000000000 vms"))
+
+		_, err = bosh.RunInStdOut("fail", "")
+		Expect(err).To(MatchError(ContainSubstring("Non-zero exit code")))
+
+		Expect(bosh.Run("-d windows-acceptance-test-1570000000000 logs --dir " + logsDir)).To(Succeed())
+	})
+
+	It("records each command with its secrets redacted", func() {
+		cassette, err := harness.LoadCassette(cassettePath)
+		Expect(err).NotTo(HaveOccurred())
+
+		interactions := cassette.Interactions()
+		Expect(interactions).To(HaveLen(3))
+
+		vms := interactions[0]
+		Expect(vms.Args).To(ContainElement("<redacted>"))
+		Expect(vms.Args).NotTo(ContainElement("client-secret"))
+		Expect(vms.Stdout).To(ContainSubstring("windows-acceptance-test-1570000000000"))
+		Expect(vms.Stdout).NotTo(ContainSubstring("client-secret"))
+		Expect(vms.Stderr).To(HavePrefix("stderr of"))
+		Expect(vms.ExitCode).To(Equal(0))
+		Expect(vms.Duration).NotTo(BeEmpty())
+
+		Expect(interactions[1].ExitCode).To(Equal(3))
+
+		logs := interactions[2]
+		Expect(logs.Args).To(ContainElement("<tmp>"))
+		Expect(logs.Files).To(HaveKeyWithValue("windows-acceptance-test-1570000000000.tgz", []byte("tarball\n")))
+	})
+
+	Context("when replaying", func() {
+		var bosh *harness.Bosh
+
+		BeforeEach(func() {
+			GinkgoT().Setenv("PATH", GinkgoT().TempDir())
+
+			bosh = newBosh()
+			var err error
+			bosh.Replayer, err = harness.LoadCassette(cassettePath)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("serves the results of matching commands, with the timestamps of the live run", func() {
+			stdout, err := bosh.RunInStdOut("-d windows-acceptance-test-1580000000000 vms", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.TrimSpace(string(stdout))).To(HaveSuffix("-d windows-acceptance-test-1580000000000 vms"))
+
+			_, err = bosh.RunInStdOut("fail", "")
+			Expect(err).To(MatchError(ContainSubstring("Non-zero exit code for cmd \"bosh -n -e 10.0.0.6 --client admin --client-secret client-secret fail\": 3")))
+
+			replayDir := GinkgoT().TempDir()
+			Expect(bosh.Run("-d windows-acceptance-test-1580000000000 logs --dir " + replayDir)).To(Succeed())
+			Expect(filepath.Join(replayDir, "windows-acceptance-test-1580000000000.tgz")).To(BeAnExistingFile())
+		})
+
+		It("replays the last matching result once all have been replayed", func() {
+			for i := 0; i < 3; i++ {
+				_, err := bosh.RunInStdOut("fail", "")
+				Expect(err).To(MatchError(ContainSubstring("Non-zero exit code")))
+			}
+		})
+
+		It("fails for commands that run in the background", func() {
+			_, err := bosh.Command("-d windows-acceptance-test-1580000000000 ssh hwc/0 --opts=-N")
+			Expect(err).To(MatchError(ContainSubstring("runs in the background and cannot be replayed")))
+		})
+
+		It("fails for commands that were not recorded", func() {
+			_, err := bosh.RunInStdOut("-d windows-acceptance-test-1580000000000 instances", "")
+			Expect(err).To(MatchError(ContainSubstring("no interaction in cassette")))
+		})
+
+		It("does not match a recorded timestamp to two different ones", func() {
+			Expect(bosh.Run("-d windows-acceptance-test-1580000000000 vms")).To(Succeed())
+
+			err := bosh.Run("-d windows-acceptance-test-1590000000000 logs --dir " + GinkgoT().TempDir())
+			Expect(err).To(MatchError(ContainSubstring("no interaction in cassette")))
+		})
+	})
+})
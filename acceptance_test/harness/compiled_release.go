@@ -0,0 +1,208 @@
+package harness
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ReleaseCache is a directory of compiled bwats-releases exported by earlier
+// runs, so that a run against the same stemcell does not compile the packages
+// again.
+type ReleaseCache struct {
+	Dir             string
+	StemcellOS      string
+	StemcellVersion string
+}
+
+// ReleaseCache is the cache of compiled releases of the config for its
+// stemcell, or nil if compiled_release_cache is not set.
+func (c *Config) ReleaseCache() (*ReleaseCache, error) {
+	if c.CompiledReleaseCache == "" {
+		return nil, nil
+	}
+
+	stemcell, err := FetchStemcellInfo(c.StemcellPath)
+	if err != nil {
+		return nil, err
+	}
+	stemcellOS := stemcell.OperatingSystem
+	if stemcellOS == "" {
+		stemcellOS = c.StemcellOs
+	}
+
+	return &ReleaseCache{Dir: c.CompiledReleaseCache, StemcellOS: stemcellOS, StemcellVersion: stemcell.Version}, nil
+}
+
+// Path is where the compiled release with the given content hash is cached.
+func (c *ReleaseCache) Path(contentHash string) string {
+	return filepath.Join(c.Dir, fmt.Sprintf("%s-%s-%s-%s.tgz", ReleaseName, contentHash, c.StemcellOS, c.StemcellVersion))
+}
+
+// Lookup returns the path of the compiled release with the given content hash,
+// if it is cached.
+func (c *ReleaseCache) Lookup(contentHash string) (string, bool) {
+	path := c.Path(contentHash)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// Export exports a version of the bwats-release compiled for the stemcell by
+// a deployment into the cache, unless it is already cached.
+func (c *ReleaseCache) Export(bosh *Bosh, deployment, version string) error {
+	exportCommand := func(dir string) string {
+		return fmt.Sprintf("-d %s export-release %s/%s %s/%s --dir %s",
+			deployment, ReleaseName, version, c.StemcellOS, c.StemcellVersion, dir)
+	}
+	if bosh.Plan != nil {
+		// The release of a dry run was not uploaded, so it cannot be inspected.
+		return bosh.Run(exportCommand(c.Dir))
+	}
+
+	contentHash, err := UploadedReleaseContentHash(bosh, version)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.Lookup(contentHash); ok {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	exportDir, err := os.MkdirTemp(c.Dir, "export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(exportDir) //nolint:errcheck
+
+	if err := bosh.Run(exportCommand(exportDir)); err != nil {
+		return err
+	}
+
+	tarballs, err := filepath.Glob(filepath.Join(exportDir, "*.tgz"))
+	if err != nil {
+		return err
+	}
+	if len(tarballs) != 1 {
+		return fmt.Errorf("expected export-release to write one tarball to %s, found %d", exportDir, len(tarballs))
+	}
+
+	// Renamed within the cache, so that a run never sees a partial tarball.
+	return os.Rename(tarballs[0], c.Path(contentHash))
+}
+
+// ReleaseManifest is the release.MF of a release tarball, source or compiled.
+type ReleaseManifest struct {
+	Name     string                `yaml:"name"`
+	Version  string                `yaml:"version"`
+	Jobs     []releaseManifestItem `yaml:"jobs"`
+	Packages []releaseManifestItem `yaml:"packages"`
+	// CompiledPackages are those of a compiled release, which has no source
+	// packages.
+	CompiledPackages []releaseManifestItem `yaml:"compiled_packages"`
+}
+
+type releaseManifestItem struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	Fingerprint string `yaml:"fingerprint"`
+}
+
+func ReadReleaseManifest(tarball string) (ReleaseManifest, error) {
+	var manifest ReleaseManifest
+
+	body, err := ReadFromTarball(tarball, "release.MF")
+	if err != nil {
+		return manifest, err
+	}
+	if err := yaml.Unmarshal(body, &manifest); err != nil {
+		return manifest, fmt.Errorf("unable to parse the release.MF of %s: %s", tarball, err)
+	}
+	return manifest, nil
+}
+
+// ContentHash identifies what the release is made of, whatever its version:
+// the fingerprints of its jobs and packages, compiled or not.
+func (m ReleaseManifest) ContentHash() string {
+	var items []string
+	for _, job := range m.Jobs {
+		items = append(items, "job "+job.Name+"/"+job.Fingerprint)
+	}
+	for _, pkg := range append(append([]releaseManifestItem{}, m.Packages...), m.CompiledPackages...) {
+		items = append(items, "package "+pkg.Name+"/"+pkg.Fingerprint)
+	}
+	return contentHash(items)
+}
+
+// UploadedReleaseContentHash is the ContentHash of an uploaded version of the
+// bwats-release.
+func UploadedReleaseContentHash(bosh *Bosh, version string) (string, error) {
+	jobs, packages, err := inspectRelease(bosh, version)
+	if err != nil {
+		return "", err
+	}
+
+	var items []string
+	for _, job := range jobs {
+		items = append(items, "job "+job)
+	}
+	for pkg := range packages {
+		items = append(items, "package "+pkg)
+	}
+	return contentHash(items), nil
+}
+
+// ReleasePackages are the packages of an uploaded version of the
+// bwats-release, by name, with what each is compiled for: "(source)" for the
+// source package, or the os/version of a stemcell.
+func ReleasePackages(bosh *Bosh, version string) (map[string][]string, error) {
+	_, packages, err := inspectRelease(bosh, version)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string][]string{}
+	for pkg, compiledFor := range packages {
+		name, _, _ := strings.Cut(pkg, "/")
+		byName[name] = append(byName[name], compiledFor...)
+	}
+	return byName, nil
+}
+
+// inspectRelease returns the jobs and packages of an uploaded version of the
+// bwats-release, which `bosh inspect-release` lists as name/fingerprint, with
+// what the packages are compiled for.
+func inspectRelease(bosh *Bosh, version string) ([]string, map[string][]string, error) {
+	tables, err := bosh.RunTables(fmt.Sprintf("inspect-release %s/%s", ReleaseName, version))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tables) < 2 {
+		return nil, nil, fmt.Errorf("expected the jobs and packages of %s/%s, got %d tables", ReleaseName, version, len(tables))
+	}
+
+	var jobs []string
+	for _, row := range tables[0] {
+		jobs = append(jobs, row["job"])
+	}
+	packages := map[string][]string{}
+	for _, row := range tables[1] {
+		packages[row["package"]] = append(packages[row["package"]], row["compiled_for"])
+	}
+	return jobs, packages, nil
+}
+
+func contentHash(items []string) string {
+	sort.Strings(items)
+	sum := sha256.Sum256([]byte(strings.Join(items, "\n")))
+	return hex.EncodeToString(sum[:])
+}
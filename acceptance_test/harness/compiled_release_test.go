@@ -0,0 +1,128 @@
+package harness_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+// fakeBoshExport lists the jobs and packages of a release compiled for
+// windows2019/2019.5, and exports a release to the --dir of export-release.
+const fakeBoshExport = `#!/bin/sh
+case "$*" in
+  *inspect-release*)
+    cat <<'EOF'
+{"Tables": [
+  {"Rows": [{"job": "simple-job/aaa"}]},
+  {"Rows": [
+    {"package": "golang-windows/bbb", "compiled_for": "(source)"},
+    {"package": "golang-windows/bbb", "compiled_for": "windows2019/2019.5"},
+    {"package": "slow-compile/ccc", "compiled_for": "(source)"}
+  ]}
+]}
+EOF
+    ;;
+  *export-release*)
+    while [ $# -gt 0 ]; do
+      if [ "$1" = "--dir" ]; then echo compiled > "$2/bwats-release-1-windows2019-2019.5-20191001.tgz"; fi
+      shift
+    done
+    ;;
+esac
+`
+
+const releaseMF = `name: bwats-release
+version: 0.dev+1
+jobs:
+- name: simple-job
+  version: aaa
+  fingerprint: aaa
+packages:
+- name: golang-windows
+  version: bbb
+  fingerprint: bbb
+- name: slow-compile
+  version: ccc
+  fingerprint: ccc
+`
+
+func writeReleaseTarball(path, manifest string) {
+	f, err := os.Create(path)
+	Expect(err).NotTo(HaveOccurred())
+	defer f.Close() //nolint:errcheck
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	Expect(tw.WriteHeader(&tar.Header{Name: "./release.MF", Mode: 0644, Size: int64(len(manifest))})).To(Succeed())
+	_, err = tw.Write([]byte(manifest))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(tw.Close()).To(Succeed())
+	Expect(gz.Close()).To(Succeed())
+}
+
+var _ = Describe("ReleaseCache", func() {
+	var (
+		bosh  *harness.Bosh
+		cache *harness.ReleaseCache
+	)
+
+	BeforeEach(func() {
+		putFakeBoshOnPath(fakeBoshExport)
+
+		bosh = &harness.Bosh{DirectorIP: "10.0.0.6", Client: "admin", ClientSecret: "secret"}
+		cache = &harness.ReleaseCache{Dir: filepath.Join(GinkgoT().TempDir(), "cache"), StemcellOS: "windows2019", StemcellVersion: "2019.5"}
+	})
+
+	It("hashes the same jobs and packages the same, whether in a tarball or uploaded", func() {
+		tarball := filepath.Join(GinkgoT().TempDir(), "release.tgz")
+		writeReleaseTarball(tarball, releaseMF)
+
+		manifest, err := harness.ReadReleaseManifest(tarball)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.Version).To(Equal("0.dev+1"))
+
+		uploaded, err := harness.UploadedReleaseContentHash(bosh, "0.dev+1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifest.ContentHash()).To(Equal(uploaded))
+
+		manifest.Packages[1].Fingerprint = "ddd"
+		Expect(manifest.ContentHash()).NotTo(Equal(uploaded))
+	})
+
+	It("lists what the packages of a release are compiled for", func() {
+		packages, err := harness.ReleasePackages(bosh, "0.dev+1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(packages).To(Equal(map[string][]string{
+			"golang-windows": {"(source)", "windows2019/2019.5"},
+			"slow-compile":   {"(source)"},
+		}))
+	})
+
+	It("exports a compiled release once, keyed by its content and the stemcell", func() {
+		contentHash, err := harness.UploadedReleaseContentHash(bosh, "0.dev+1")
+		Expect(err).NotTo(HaveOccurred())
+		_, ok := cache.Lookup(contentHash)
+		Expect(ok).To(BeFalse())
+
+		Expect(cache.Export(bosh, "windows-acceptance-test-1", "0.dev+1")).To(Succeed())
+
+		path, ok := cache.Lookup(contentHash)
+		Expect(ok).To(BeTrue())
+		Expect(filepath.Base(path)).To(Equal("bwats-release-" + contentHash + "-windows2019-2019.5.tgz"))
+		Expect(os.ReadFile(path)).To(Equal([]byte("compiled\n")))
+
+		Expect(os.WriteFile(path, []byte("cached\n"), 0644)).To(Succeed())
+		Expect(cache.Export(bosh, "windows-acceptance-test-1", "0.dev+1")).To(Succeed())
+		Expect(os.ReadFile(path)).To(Equal([]byte("cached\n")))
+
+		entries, err := os.ReadDir(cache.Dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+	})
+})
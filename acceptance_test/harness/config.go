@@ -0,0 +1,194 @@
+// Package harness drives the director for the acceptance suite and the bwats
+// command: it reads the test config, creates and uploads the bwats-release and
+// the stemcell, deploys manifests and fetches logs.
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/expectations"
+)
+
+const (
+	DefaultVmExtensions   = "500GB_ephemeral_disk"
+	DefaultScaleInstances = 3
+	DefaultHWCPort        = 54321
+)
+
+// DefaultUpdateExclusions are the updates check-updates does not report as
+// missing unless updates.exclude is set: Defender definition updates, which
+// are released several times a day.
+var DefaultUpdateExclusions = []string{"KB2267602", "KB4052623"}
+
+// Config is the JSON test config documented in the README.
+type Config struct {
+	Bosh struct {
+		CaCert       string `json:"ca_cert"`
+		Client       string `json:"client"`
+		ClientSecret string `json:"client_secret"`
+		Target       string `json:"target"`
+	} `json:"bosh"`
+	StemcellPath              string   `json:"stemcell_path"`
+	StemcellOs                string   `json:"stemcell_os"`
+	Az                        string   `json:"az"`
+	VmType                    string   `json:"vm_type"`
+	RootEphemeralVmType       string   `json:"root_ephemeral_vm_type"`
+	VmExtensions              string   `json:"vm_extensions"`
+	Network                   string   `json:"network"`
+	DefaultUsername           string   `json:"default_username"`
+	DefaultPassword           string   `json:"default_password"`
+	SkipCleanup               bool     `json:"skip_cleanup"`
+	MountEphemeralDisk        bool     `json:"mount_ephemeral_disk"`
+	SkipMSUpdateTest          bool     `json:"skip_ms_update_test"`
+	SSHDisabledByDefault      bool     `json:"ssh_disabled_by_default"`
+	SecurityComplianceApplied bool     `json:"security_compliance_applied"`
+	ExpectationsPath          string   `json:"expectations_path"`
+	InventoryPath             string   `json:"inventory_path"`
+	BaselineInventoryPath     string   `json:"baseline_inventory_path"`
+	BaselineStemcellPath      string   `json:"baseline_stemcell_path"`
+	PersistentDiskType        string   `json:"persistent_disk_type"`
+	ScaleInstances            int      `json:"scale_instances"`
+	TrustedCert               string   `json:"trusted_cert"`
+	StaticIP                  string   `json:"static_ip"`
+	SecondNetwork             string   `json:"second_network"`
+	HWCPort                   int      `json:"hwc_port"`
+	ContainerBaseLayerPath    string   `json:"container_base_layer_path"`
+	ExistingDeployment        string   `json:"existing_deployment"`
+	ExistingReleaseVersion    string   `json:"existing_release_version"`
+	GoVersion                 string   `json:"go_version"`
+	GoSHA256                  string   `json:"go_sha256"`
+	SlowCompile               Workload `json:"slow_compile"`
+	CompiledReleaseCache      string   `json:"compiled_release_cache"`
+	Updates                   struct {
+		OfflineCatalogPath string   `json:"offline_catalog_path"`
+		Exclude            []string `json:"exclude"`
+	} `json:"updates"`
+	Checks struct {
+		Focus []string `json:"focus"`
+		Skip  []string `json:"skip"`
+	} `json:"checks"`
+}
+
+// LoadConfig reads a config file and fills in the defaults of optional fields,
+// resolving paths relative to root, the directory of the suite.
+func LoadConfig(path, root string) (*Config, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(body, root)
+}
+
+// ParseConfig is LoadConfig for a config that has already been read.
+func ParseConfig(body []byte, root string) (*Config, error) {
+	var c Config
+	if err := json.Unmarshal(body, &c); err != nil {
+		return nil, fmt.Errorf("unable to parse config: %s", err)
+	}
+
+	if c.StemcellOs == "" {
+		return nil, fmt.Errorf("missing required field: 'stemcell_os'")
+	}
+
+	if c.VmExtensions == "" {
+		c.VmExtensions = DefaultVmExtensions
+	}
+	if c.ScaleInstances == 0 {
+		c.ScaleInstances = DefaultScaleInstances
+	}
+	if c.HWCPort == 0 {
+		c.HWCPort = DefaultHWCPort
+	}
+	if c.ExpectationsPath == "" {
+		c.ExpectationsPath = filepath.Join(root, "assets", "expectations.yml")
+	}
+	if c.GoVersion == "" {
+		c.GoVersion = DefaultGoVersion
+	}
+	if c.GoVersion == DefaultGoVersion && c.GoSHA256 == "" {
+		c.GoSHA256 = defaultGoSHA256
+	}
+	if c.Updates.Exclude == nil {
+		c.Updates.Exclude = DefaultUpdateExclusions
+	}
+	if c.SlowCompile.Files == 0 {
+		c.SlowCompile.Files = DefaultWorkloadFiles
+	}
+	if c.SlowCompile.FanOut == 0 {
+		c.SlowCompile.FanOut = DefaultWorkloadFanOut
+	}
+	if c.SlowCompile.TargetDuration == "" {
+		c.SlowCompile.TargetDuration = DefaultWorkloadTargetDuration
+	}
+
+	return &c, nil
+}
+
+// Validate checks that the fields needed to reach the director and deploy are
+// set, and that the files the config refers to exist.
+func (c *Config) Validate() error {
+	required := []struct{ field, value string }{
+		{"bosh.target", c.Bosh.Target},
+		{"bosh.client", c.Bosh.Client},
+		{"bosh.client_secret", c.Bosh.ClientSecret},
+		{"stemcell_path", c.StemcellPath},
+		{"az", c.Az},
+		{"vm_type", c.VmType},
+		{"network", c.Network},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			return fmt.Errorf("missing required field: '%s'", r.field)
+		}
+	}
+
+	if matches, err := filepath.Glob(c.StemcellPath); err != nil || len(matches) != 1 {
+		return fmt.Errorf("stemcell_path %q must match exactly one file", c.StemcellPath)
+	}
+
+	optional := []struct{ field, path string }{
+		{"baseline_inventory_path", c.BaselineInventoryPath},
+		{"container_base_layer_path", c.ContainerBaseLayerPath},
+		{"updates.offline_catalog_path", c.Updates.OfflineCatalogPath},
+	}
+	for _, o := range optional {
+		if o.path == "" {
+			continue
+		}
+		if _, err := os.Stat(o.path); err != nil {
+			return fmt.Errorf("%s: %s", o.field, err)
+		}
+	}
+	if c.BaselineStemcellPath != "" {
+		if matches, err := filepath.Glob(c.BaselineStemcellPath); err != nil || len(matches) != 1 {
+			return fmt.Errorf("baseline_stemcell_path %q must match exactly one file", c.BaselineStemcellPath)
+		}
+	}
+
+	if c.GoSHA256 == "" {
+		return fmt.Errorf("missing required field: 'go_sha256', the checksum of %s", c.GoToolchain().ZipFile())
+	}
+	if err := c.SlowCompile.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := c.Expectations(); err != nil {
+		return fmt.Errorf("expectations_path: %s", err)
+	}
+
+	return nil
+}
+
+// Expectations are the expectations for the stemcell OS of the config.
+func (c *Config) Expectations() (expectations.Expectations, error) {
+	f, err := expectations.Load(c.ExpectationsPath)
+	if err != nil {
+		return expectations.Expectations{}, err
+	}
+
+	return f.For(c.StemcellOs), nil
+}
@@ -0,0 +1,113 @@
+package harness_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+var _ = Describe("Config", func() {
+	root := filepath.Join("..")
+
+	Describe("ParseConfig", func() {
+		It("fills in the defaults of optional fields", func() {
+			config, err := harness.ParseConfig([]byte(`{"stemcell_os": "windows2019"}`), root)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(config.VmExtensions).To(Equal(harness.DefaultVmExtensions))
+			Expect(config.ScaleInstances).To(Equal(harness.DefaultScaleInstances))
+			Expect(config.HWCPort).To(Equal(harness.DefaultHWCPort))
+			Expect(config.ExpectationsPath).To(Equal(filepath.Join(root, "assets", "expectations.yml")))
+			Expect(config.GoToolchain()).To(Equal(harness.GoToolchain{
+				Version: harness.DefaultGoVersion,
+				SHA256:  "502712c0e29edc6b9cda6fa5e4b6ff9b36e27d225373baead8708c9634aa8e50",
+			}))
+			Expect(config.Updates.Exclude).To(Equal(harness.DefaultUpdateExclusions))
+			Expect(config.SlowCompile).To(Equal(harness.Workload{
+				Files:          harness.DefaultWorkloadFiles,
+				FanOut:         harness.DefaultWorkloadFanOut,
+				TargetDuration: harness.DefaultWorkloadTargetDuration,
+			}))
+		})
+
+		It("keeps the values that are set", func() {
+			config, err := harness.ParseConfig([]byte(`{"stemcell_os": "windows2019", "vm_extensions": "large", "hwc_port": 8080}`), root)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(config.VmExtensions).To(Equal("large"))
+			Expect(config.HWCPort).To(Equal(8080))
+		})
+
+		It("requires stemcell_os", func() {
+			_, err := harness.ParseConfig([]byte(`{}`), root)
+			Expect(err).To(MatchError(ContainSubstring("stemcell_os")))
+		})
+
+		It("keeps an empty list of update exclusions", func() {
+			config, err := harness.ParseConfig([]byte(`{"stemcell_os": "windows2019", "updates": {"exclude": []}}`), root)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(config.Updates.Exclude).To(BeEmpty())
+			Expect(config.Updates.Exclude).NotTo(BeNil())
+		})
+
+		It("rejects invalid JSON", func() {
+			_, err := harness.ParseConfig([]byte(`{`), root)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Validate", func() {
+		var config *harness.Config
+
+		BeforeEach(func() {
+			stemcellPath := filepath.Join(GinkgoT().TempDir(), "light-bosh-stemcell.tgz")
+			Expect(os.WriteFile(stemcellPath, nil, 0644)).To(Succeed())
+
+			var err error
+			config, err = harness.ParseConfig([]byte(`{
+				"bosh": {"target": "10.0.0.6", "client": "admin", "client_secret": "secret"},
+				"stemcell_os": "windows2019",
+				"az": "z1",
+				"vm_type": "large",
+				"network": "default"
+			}`), root)
+			Expect(err).NotTo(HaveOccurred())
+			config.StemcellPath = stemcellPath
+		})
+
+		It("accepts a complete config", func() {
+			Expect(config.Validate()).To(Succeed())
+		})
+
+		It("requires the director credentials", func() {
+			config.Bosh.ClientSecret = ""
+			Expect(config.Validate()).To(MatchError(ContainSubstring("bosh.client_secret")))
+		})
+
+		It("requires the checksum of a Go version other than the default", func() {
+			config.GoVersion = "1.22.5"
+			config.GoSHA256 = ""
+			Expect(config.Validate()).To(MatchError(ContainSubstring("go_sha256")))
+		})
+
+		It("requires the stemcell to exist", func() {
+			config.StemcellPath = filepath.Join(GinkgoT().TempDir(), "*.tgz")
+			Expect(config.Validate()).To(MatchError(ContainSubstring("stemcell_path")))
+		})
+
+		It("requires optional files to exist when they are set", func() {
+			config.ContainerBaseLayerPath = filepath.Join(GinkgoT().TempDir(), "base-layer.zip")
+			Expect(config.Validate()).To(MatchError(ContainSubstring("container_base_layer_path")))
+		})
+
+		It("requires the expectations to load", func() {
+			config.ExpectationsPath = filepath.Join(GinkgoT().TempDir(), "expectations.yml")
+			Expect(config.Validate()).To(MatchError(ContainSubstring("expectations_path")))
+		})
+	})
+})
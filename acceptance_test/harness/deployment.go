@@ -0,0 +1,158 @@
+package harness
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Deployment is a row of `bosh deployments`.
+type Deployment struct {
+	Name      string
+	Releases  []string
+	Stemcells []string
+}
+
+// FindDeployment returns the deployment with the given name.
+func FindDeployment(bosh *Bosh, name string) (Deployment, error) {
+	rows, err := bosh.RunTable("deployments")
+	if err != nil {
+		return Deployment{}, err
+	}
+
+	for _, row := range rows {
+		if row["name"] == name {
+			return Deployment{
+				Name:      name,
+				Releases:  strings.Fields(row["release_s"]),
+				Stemcells: strings.Fields(row["stemcell_s"]),
+			}, nil
+		}
+	}
+	return Deployment{}, fmt.Errorf("deployment %s does not exist", name)
+}
+
+// ReleaseVersion is the version of a release used by the deployment.
+func (d Deployment) ReleaseVersion(name string) (string, bool) {
+	for _, release := range d.Releases {
+		if releaseName, version, ok := strings.Cut(release, "/"); ok && releaseName == name {
+			return version, true
+		}
+	}
+	return "", false
+}
+
+// UsesStemcell reports whether the deployment uses a stemcell.
+func (d Deployment) UsesStemcell(name, version string) bool {
+	for _, stemcell := range d.Stemcells {
+		if stemcell == name+"/"+version {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleaseExists reports whether a version of the bwats-release was uploaded.
+func ReleaseExists(bosh *Bosh, version string) (bool, error) {
+	rows, err := bosh.RunTable("releases")
+	if err != nil {
+		return false, err
+	}
+
+	for _, row := range rows {
+		// Versions in use by a deployment are marked with a *.
+		if row["name"] == ReleaseName && strings.TrimSuffix(row["version"], "*") == version {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type manifestJobs struct {
+	InstanceGroups []struct {
+		Name string `yaml:"name"`
+		Jobs []struct {
+			Name string `yaml:"name"`
+		} `yaml:"jobs"`
+	} `yaml:"instance_groups"`
+}
+
+// CompatibleJobs checks that every instance group of the expected manifest is
+// in the actual one, with at least the same jobs.
+func CompatibleJobs(expected, actual []byte) error {
+	var e, a manifestJobs
+	if err := yaml.Unmarshal(expected, &e); err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(actual, &a); err != nil {
+		return err
+	}
+
+	actualJobs := map[string]map[string]bool{}
+	for _, group := range a.InstanceGroups {
+		actualJobs[group.Name] = map[string]bool{}
+		for _, job := range group.Jobs {
+			actualJobs[group.Name][job.Name] = true
+		}
+	}
+
+	var missing []string
+	for _, group := range e.InstanceGroups {
+		jobs, ok := actualJobs[group.Name]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("instance group %s", group.Name))
+			continue
+		}
+		for _, job := range group.Jobs {
+			if !jobs[job.Name] {
+				missing = append(missing, fmt.Sprintf("job %s in instance group %s", job.Name, group.Name))
+			}
+		}
+	}
+
+	if len(missing) != 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// VerifyExistingDeployment checks that the existing_deployment of the config
+// uses the stemcell under test, the existing_release_version if set, and has
+// the instance groups and jobs of the main manifest. It returns the version of
+// the bwats-release the deployment uses.
+func (c *Config) VerifyExistingDeployment(bosh *Bosh, root string, stemcell StemcellYML) (string, error) {
+	deployment, err := FindDeployment(bosh, c.ExistingDeployment)
+	if err != nil {
+		return "", err
+	}
+
+	if !deployment.UsesStemcell(stemcell.Name, stemcell.Version) {
+		return "", fmt.Errorf("deployment %s uses %s, not the stemcell under test %s/%s",
+			deployment.Name, strings.Join(deployment.Stemcells, ", "), stemcell.Name, stemcell.Version)
+	}
+
+	releaseVersion, ok := deployment.ReleaseVersion(ReleaseName)
+	if !ok {
+		return "", fmt.Errorf("deployment %s does not use %s", deployment.Name, ReleaseName)
+	}
+	if c.ExistingReleaseVersion != "" && releaseVersion != c.ExistingReleaseVersion {
+		return "", fmt.Errorf("deployment %s uses %s/%s, not existing_release_version %s",
+			deployment.Name, ReleaseName, releaseVersion, c.ExistingReleaseVersion)
+	}
+
+	expected, err := os.ReadFile(MainManifestPath(root))
+	if err != nil {
+		return "", err
+	}
+	actual, err := bosh.RunInStdOut(fmt.Sprintf("-d %s manifest", deployment.Name), "")
+	if err != nil {
+		return "", err
+	}
+	if err := CompatibleJobs(expected, actual); err != nil {
+		return "", fmt.Errorf("deployment %s is not compatible with %s: %s", deployment.Name, MainManifestPath(root), err)
+	}
+
+	return releaseVersion, nil
+}
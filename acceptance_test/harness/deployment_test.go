@@ -0,0 +1,78 @@
+package harness_test
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+var _ = Describe("Deployment", func() {
+	deployment := harness.Deployment{
+		Name:      "windows-acceptance-test-1",
+		Releases:  []string{"bpm/1.2.3", "bwats-release/0.dev+1"},
+		Stemcells: []string{"bosh-vsphere-esxi-windows2019-go_agent/2019.50"},
+	}
+
+	It("finds the version of a release it uses", func() {
+		version, ok := deployment.ReleaseVersion("bwats-release")
+		Expect(ok).To(BeTrue())
+		Expect(version).To(Equal("0.dev+1"))
+
+		_, ok = deployment.ReleaseVersion("windows-utilities")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("matches the stemcell it uses by name and version", func() {
+		Expect(deployment.UsesStemcell("bosh-vsphere-esxi-windows2019-go_agent", "2019.50")).To(BeTrue())
+		Expect(deployment.UsesStemcell("bosh-vsphere-esxi-windows2019-go_agent", "2019.51")).To(BeFalse())
+	})
+})
+
+var _ = Describe("CompatibleJobs", func() {
+	expected := []byte(`
+instance_groups:
+  - name: check-multiple
+    azs: [((AZ))]
+    jobs:
+      - name: simple-job
+      - name: check-system
+        properties: ((CheckSystemProperties))
+  - name: check-updates
+    jobs:
+      - name: check-updates
+`)
+
+	It("accepts a manifest with the same instance groups and more jobs", func() {
+		Expect(harness.CompatibleJobs(expected, []byte(`
+instance_groups:
+- name: check-multiple
+  jobs:
+  - name: check-system
+  - name: simple-job
+  - name: windows-utilities
+- name: check-updates
+  jobs:
+  - name: check-updates
+`))).To(Succeed())
+	})
+
+	It("names the missing instance groups and jobs", func() {
+		err := harness.CompatibleJobs(expected, []byte(`
+instance_groups:
+- name: check-multiple
+  jobs:
+  - name: simple-job
+`))
+		Expect(err).To(MatchError(ContainSubstring("job check-system in instance group check-multiple")))
+		Expect(err).To(MatchError(ContainSubstring("instance group check-updates")))
+	})
+
+	It("accepts the manifest shipped with the suite as compatible with itself", func() {
+		manifest, err := os.ReadFile(harness.MainManifestPath(".."))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(harness.CompatibleJobs(manifest, manifest)).To(Succeed())
+	})
+})
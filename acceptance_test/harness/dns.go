@@ -0,0 +1,63 @@
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const boshDNSRelease = "bosh-dns"
+
+// HasBoshDNS returns whether a runtime config of the director adds the
+// bosh-dns release, without which addresses rendered with use_dns_addresses
+// do not resolve.
+func HasBoshDNS(bosh *Bosh) (bool, error) {
+	configs, err := bosh.RunTable("configs --type=runtime")
+	if err != nil {
+		return false, err
+	}
+
+	for _, config := range configs {
+		stdout, err := bosh.RunInStdOut(fmt.Sprintf("runtime-config --name=%s --json", config["name"]), "")
+		if err != nil {
+			return false, err
+		}
+		// The content of the runtime config is printed as a block, apart from
+		// the lines about the environment.
+		var output struct {
+			Blocks []string
+		}
+		if err := json.Unmarshal(stdout, &output); err != nil {
+			return false, err
+		}
+		adds, err := AddsBoshDNS([]byte(strings.Join(output.Blocks, "")))
+		if err != nil {
+			return false, fmt.Errorf("runtime config %s: %s", config["name"], err)
+		}
+		if adds {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddsBoshDNS returns whether a runtime config adds the bosh-dns release.
+func AddsBoshDNS(runtimeConfig []byte) (bool, error) {
+	var config struct {
+		Releases []struct {
+			Name string `yaml:"name"`
+		} `yaml:"releases"`
+	}
+	if err := yaml.Unmarshal(runtimeConfig, &config); err != nil {
+		return false, fmt.Errorf("unable to parse: %s", err)
+	}
+
+	for _, release := range config.Releases {
+		if release.Name == boshDNSRelease {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,41 @@
+package harness_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+var _ = Describe("AddsBoshDNS", func() {
+	It("finds the bosh-dns release among those of a runtime config", func() {
+		adds, err := harness.AddsBoshDNS([]byte(`
+releases:
+- name: os-conf
+  version: 22.2.1
+- name: bosh-dns
+  version: 1.38.2
+addons:
+- name: bosh-dns-windows
+  include:
+    stemcell:
+    - os: windows2019
+  jobs:
+  - name: bosh-dns-windows
+    release: bosh-dns
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(adds).To(BeTrue())
+	})
+
+	It("does not find it in a runtime config without it", func() {
+		adds, err := harness.AddsBoshDNS([]byte("releases:\n- name: os-conf\n  version: 22.2.1\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(adds).To(BeFalse())
+	})
+
+	It("fails on something else", func() {
+		_, err := harness.AddsBoshDNS([]byte("releases: os-conf"))
+		Expect(err).To(MatchError(ContainSubstring("unable to parse")))
+	})
+})
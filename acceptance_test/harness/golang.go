@@ -0,0 +1,166 @@
+package harness
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	DefaultGoVersion = "1.12.7"
+	defaultGoSHA256  = "502712c0e29edc6b9cda6fa5e4b6ff9b36e27d225373baead8708c9634aa8e50"
+
+	golangPackage   = "golang-windows"
+	golangGenerated = "Generated by the acceptance test harness for go_version in the test config. DO NOT EDIT."
+)
+
+// GoToolchain is the Go distribution the golang-windows package installs, and
+// that the other packages of the bwats-release are compiled with.
+type GoToolchain struct {
+	Version string
+	// SHA256 is the checksum of the zip, checked before it is added as a blob.
+	SHA256 string
+}
+
+// GoToolchain is the Go version and checksum of the config.
+func (c *Config) GoToolchain() GoToolchain {
+	return GoToolchain{Version: c.GoVersion, SHA256: c.GoSHA256}
+}
+
+// ZipFile is the name of the windows-amd64 zip of the version, both on the Go
+// download site and as a blob.
+func (g GoToolchain) ZipFile() string {
+	return fmt.Sprintf("go%s.windows-amd64.zip", g.Version)
+}
+
+func (g GoToolchain) URL() string {
+	return "https://storage.googleapis.com/golang/" + g.ZipFile()
+}
+
+func (g GoToolchain) blobPath() string {
+	return golangPackage + "/" + g.ZipFile()
+}
+
+// Verify checks the checksum of the zip at path.
+func (g GoToolchain) Verify(path string) error {
+	sum, err := sha256File(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sum, g.SHA256) {
+		return fmt.Errorf("sha256 of %s is %s, expected %s for go %s", path, sum, g.SHA256, g.Version)
+	}
+	return nil
+}
+
+// Render writes the spec and packaging of the golang-windows package for the
+// version into the release, and its entry in config/blobs.yml for the zip at
+// zipPath, replacing those of any other version. The entry is left to add-blob
+// when the zip is not local, e.g. in a dry run.
+func (g GoToolchain) Render(releaseDir, zipPath string) error {
+	packageDir := filepath.Join(releaseDir, "packages", golangPackage)
+
+	spec := fmt.Sprintf(`---
+# %s
+name: %s
+
+dependencies: []
+
+files:
+- %s
+`, golangGenerated, golangPackage, g.blobPath())
+	if err := os.WriteFile(filepath.Join(packageDir, "spec"), []byte(spec), 0644); err != nil {
+		return err
+	}
+
+	packaging := fmt.Sprintf(`# %s
+$path="%s"
+
+try {
+    Add-Type -AssemblyName System.IO.Compression.FileSystem
+    function Unzip {
+        param([string]$zipfile, [string]$outpath)
+
+        [System.IO.Compression.ZipFile]::ExtractToDirectory($zipfile, $outpath)
+    }
+
+    Unzip "${path}" "${env:BOSH_INSTALL_TARGET}"
+
+    # Dot-sourced by the packages compiled with this Go, so that they do not
+    # depend on the layout of the distribution. GOPATH mode is used, as the
+    # sources of the release have no go.mod.
+    Set-Content -Path "${env:BOSH_INSTALL_TARGET}\go-env.ps1" -Value @'
+$env:GOROOT=Join-Path $PSScriptRoot "go"
+$env:PATH="${env:GOROOT}\bin;${env:PATH}"
+$env:GO111MODULE="off"
+'@
+} catch {
+    Write-Error $_.Exception.Message
+    Exit 1
+}
+
+Exit 0
+`, golangGenerated, g.blobPath())
+	if err := os.WriteFile(filepath.Join(packageDir, "packaging"), []byte(packaging), 0644); err != nil {
+		return err
+	}
+
+	return g.renderBlobs(filepath.Join(releaseDir, "config", "blobs.yml"), zipPath)
+}
+
+func (g GoToolchain) renderBlobs(blobsPath, zipPath string) error {
+	body, err := os.ReadFile(blobsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var blobs yaml.MapSlice
+	if err := yaml.Unmarshal(body, &blobs); err != nil {
+		return fmt.Errorf("unable to parse %s: %s", blobsPath, err)
+	}
+
+	rendered := yaml.MapSlice{}
+	for _, blob := range blobs {
+		if name, ok := blob.Key.(string); ok && strings.HasPrefix(name, golangPackage+"/") {
+			continue
+		}
+		rendered = append(rendered, blob)
+	}
+
+	if info, err := os.Stat(zipPath); err == nil {
+		sum, err := sha256File(zipPath)
+		if err != nil {
+			return err
+		}
+		rendered = append(yaml.MapSlice{{Key: g.blobPath(), Value: yaml.MapSlice{
+			{Key: "size", Value: info.Size()},
+			{Key: "sha", Value: "sha256:" + sum},
+		}}}, rendered...)
+	}
+
+	body, err = yaml.Marshal(rendered)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(blobsPath, body, 0644)
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,89 @@
+package harness_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+var _ = Describe("GoToolchain", func() {
+	var (
+		releaseDir string
+		zipPath    string
+		toolchain  harness.GoToolchain
+	)
+
+	BeforeEach(func() {
+		releaseDir = GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(releaseDir, "packages", "golang-windows"), 0755)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(releaseDir, "config"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(releaseDir, "config", "blobs.yml"), []byte(`golang-windows/go1.12.7.windows-amd64.zip:
+  size: 141335938
+  sha: sha256:502712c0e29edc6b9cda6fa5e4b6ff9b36e27d225373baead8708c9634aa8e50
+lgpo/LGPO.exe:
+  size: 410088
+  sha: sha256:f218db26d05c80d105dc779ba4e99c72f37ffc9f78d70d359bbe230713b765b4
+`), 0644)).To(Succeed())
+
+		zipPath = filepath.Join(GinkgoT().TempDir(), "go.zip")
+		Expect(os.WriteFile(zipPath, []byte("go"), 0644)).To(Succeed())
+
+		toolchain = harness.GoToolchain{
+			Version: "1.22.5",
+			// sha256 of "go"
+			SHA256: "4cd0e21a9a0795a14ec9aa5f0e7d1abff0492565770e43eafdf1e3e8afed1f33",
+		}
+	})
+
+	It("names the zip and its URL after the version", func() {
+		Expect(toolchain.ZipFile()).To(Equal("go1.22.5.windows-amd64.zip"))
+		Expect(toolchain.URL()).To(Equal("https://storage.googleapis.com/golang/go1.22.5.windows-amd64.zip"))
+	})
+
+	It("renders the golang-windows package for the version", func() {
+		Expect(toolchain.Render(releaseDir, zipPath)).To(Succeed())
+
+		spec, err := os.ReadFile(filepath.Join(releaseDir, "packages", "golang-windows", "spec"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(spec)).To(ContainSubstring("files:\n- golang-windows/go1.22.5.windows-amd64.zip\n"))
+
+		packaging, err := os.ReadFile(filepath.Join(releaseDir, "packages", "golang-windows", "packaging"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(packaging)).To(ContainSubstring(`$path="golang-windows/go1.22.5.windows-amd64.zip"`))
+		Expect(string(packaging)).To(ContainSubstring(`go-env.ps1`))
+	})
+
+	It("replaces the blob of any other version in blobs.yml", func() {
+		Expect(toolchain.Render(releaseDir, zipPath)).To(Succeed())
+
+		blobs, err := os.ReadFile(filepath.Join(releaseDir, "config", "blobs.yml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(blobs)).To(Equal(`golang-windows/go1.22.5.windows-amd64.zip:
+  size: 2
+  sha: sha256:` + toolchain.SHA256 + `
+lgpo/LGPO.exe:
+  size: 410088
+  sha: sha256:f218db26d05c80d105dc779ba4e99c72f37ffc9f78d70d359bbe230713b765b4
+`))
+	})
+
+	It("leaves the blob to add-blob when the zip is not local", func() {
+		Expect(toolchain.Render(releaseDir, toolchain.URL())).To(Succeed())
+
+		blobs, err := os.ReadFile(filepath.Join(releaseDir, "config", "blobs.yml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(blobs)).NotTo(ContainSubstring("golang-windows"))
+		Expect(string(blobs)).To(ContainSubstring("lgpo/LGPO.exe"))
+	})
+
+	It("verifies the checksum of the zip", func() {
+		Expect(toolchain.Verify(zipPath)).To(Succeed())
+
+		toolchain.SHA256 = "0000"
+		Expect(toolchain.Verify(zipPath)).To(MatchError(ContainSubstring("expected 0000 for go 1.22.5")))
+	})
+})
@@ -0,0 +1,22 @@
+package harness_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHarness(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Harness Suite")
+}
+
+// putFakeBoshOnPath makes script the bosh CLI for the current spec.
+func putFakeBoshOnPath(script string) {
+	binDir := GinkgoT().TempDir()
+	Expect(os.WriteFile(filepath.Join(binDir, "bosh"), []byte(script), 0755)).To(Succeed())
+	GinkgoT().Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
@@ -0,0 +1,73 @@
+package harness
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DownloadLogsTarball downloads the logs of an instance into dir and returns
+// the path of the tarball, for reading several log files from one download.
+func DownloadLogsTarball(bosh *Bosh, deployment string, instanceName string, index int, dir string) (string, error) {
+	if err := bosh.Run(fmt.Sprintf("-d %s logs %s/%d --dir %s", deployment, instanceName, index, dir)); err != nil {
+		return "", err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s.%s.%d-*.tgz", deployment, instanceName, index)))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) != 1 {
+		return "", fmt.Errorf("expected one logs tarball for %s/%d in %s, found %d", instanceName, index, dir, len(matches))
+	}
+
+	return matches[0], nil
+}
+
+// DownloadDeploymentLogs downloads the logs of every instance of a deployment
+// into dir and returns the paths of the tarballs.
+func DownloadDeploymentLogs(bosh *Bosh, deployment string, dir string) ([]string, error) {
+	if err := bosh.Run(fmt.Sprintf("-d %s logs --dir %s", deployment, dir)); err != nil {
+		return nil, err
+	}
+
+	return filepath.Glob(filepath.Join(dir, fmt.Sprintf("%s*.tgz", deployment)))
+}
+
+// RunErrandAndDownloadLogs runs an errand, downloading its logs into dir, and
+// returns the paths of the tarballs, one for each instance it ran on. The logs
+// are returned along with the error when the errand fails, so that the caller
+// can report what it found.
+func RunErrandAndDownloadLogs(bosh *Bosh, deployment string, errandName string, dir string) ([]string, error) {
+	errandErr := bosh.Run(fmt.Sprintf("-d %s run-errand %s --download-logs --logs-dir %s", deployment, errandName, dir))
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tgz"))
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		if errandErr != nil {
+			return nil, errandErr
+		}
+		return nil, fmt.Errorf("errand %s did not download any logs", errandName)
+	}
+
+	return matches, errandErr
+}
+
+// ReadFromTarball returns the contents of a file in a logs tarball, e.g.
+// "inventory/inventory.json".
+func ReadFromTarball(tarball string, path string) ([]byte, error) {
+	cmd := exec.Command("tar", "xf", tarball, "-O", "./"+path)
+	stdout, err := cmd.Output()
+	if err != nil {
+		var stderr []byte
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr = exitErr.Stderr
+		}
+		return nil, fmt.Errorf("Non-zero exit code for cmd %q: %s\nSTDERR:\n%s\n", strings.Join(cmd.Args, " "), err, stderr)
+	}
+
+	return stdout, nil
+}
@@ -0,0 +1,210 @@
+package harness
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/expectations"
+)
+
+type ManifestProperties struct {
+	DeploymentName            string
+	ReleaseName               string
+	AZ                        string
+	VmType                    string
+	RootEphemeralVmType       string
+	VmExtensions              string
+	Network                   string
+	StemcellOs                string
+	StemcellVersion           string
+	ReleaseVersion            string
+	DefaultUsername           string
+	DefaultPassword           string
+	MountEphemeralDisk        bool
+	SSHDisabledByDefault      bool
+	SecurityComplianceApplied bool
+	Expectations              expectations.Expectations
+	ChecksFocus               []string
+	ChecksSkip                []string
+	UpdatesOfflineCatalog     bool
+	UpdatesExclude            []string
+}
+
+// ToVarsString are the -v flags of the string manifest variables.
+func (m ManifestProperties) ToVarsString() string {
+	manifest := m.toMap()
+
+	var b bytes.Buffer
+	for k, v := range manifest {
+		if v != "" {
+			fmt.Fprintf(&b, "-v %s=%s ", k, v)
+		}
+	}
+
+	boolOperators := []string{
+		fmt.Sprintf("-v MountEphemeralDisk=%t", m.MountEphemeralDisk),
+	}
+	b.WriteString(strings.Join(boolOperators, " "))
+
+	return b.String()
+}
+
+// ToVarsFile writes the structured manifest variables, which cannot be passed
+// with -v, and any extra variables to a vars file suitable for -l. The caller
+// removes the file.
+func (m ManifestProperties) ToVarsFile(extraVars map[string]interface{}) (string, error) {
+	vars := map[string]interface{}{
+		"CheckSystemProperties": m.checkSystemProperties(),
+		"CheckUpdatesProperties": map[string]interface{}{
+			"check_updates": map[string]interface{}{
+				"offline_catalog": m.UpdatesOfflineCatalog,
+				"exclude":         m.UpdatesExclude,
+			},
+		},
+	}
+	for k, v := range extraVars {
+		vars[k] = v
+	}
+
+	body, err := yaml.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+
+	varsFile, err := os.CreateTemp("", "vars-*.yml")
+	if err != nil {
+		return "", err
+	}
+	defer varsFile.Close() //nolint:errcheck
+
+	if _, err := varsFile.Write(body); err != nil {
+		return "", err
+	}
+
+	return varsFile.Name(), nil
+}
+
+// checkSystemProperties are the properties of the check-system job. Properties
+// declared in the check catalog but not set here use their catalog default.
+func (m ManifestProperties) checkSystemProperties() map[string]interface{} {
+	properties := map[string]interface{}{
+		"ssh": map[string]interface{}{
+			"disabled_by_default": m.SSHDisabledByDefault,
+		},
+		"security_compliance": map[string]interface{}{
+			"expected_to_comply": m.SecurityComplianceApplied,
+		},
+		"expectations": m.Expectations,
+		"checks": map[string]interface{}{
+			"focus": m.ChecksFocus,
+			"skip":  m.ChecksSkip,
+		},
+	}
+
+	password := map[string]interface{}{}
+	if m.DefaultUsername != "" {
+		password["default_username"] = m.DefaultUsername
+	}
+	if m.DefaultPassword != "" {
+		password["default_password"] = m.DefaultPassword
+	}
+	if len(password) != 0 {
+		properties["password"] = password
+	}
+
+	return properties
+}
+
+func (m ManifestProperties) toMap() map[string]string {
+	manifest := make(map[string]string)
+
+	manifest["DeploymentName"] = m.DeploymentName
+	manifest["ReleaseName"] = m.ReleaseName
+	manifest["AZ"] = m.AZ
+	manifest["VmType"] = m.VmType
+	manifest["RootEphemeralVmType"] = m.RootEphemeralVmType
+	manifest["VmExtensions"] = m.VmExtensions
+	manifest["Network"] = m.Network
+	manifest["StemcellOs"] = m.StemcellOs
+	manifest["StemcellVersion"] = m.StemcellVersion
+	manifest["ReleaseVersion"] = m.ReleaseVersion
+
+	return manifest
+}
+
+// ManifestProperties are the manifest variables set from the config for a
+// deployment.
+func (c *Config) ManifestProperties(deploymentName, stemcellVersion, bwatsVersion string) (ManifestProperties, error) {
+	e, err := c.Expectations()
+	if err != nil {
+		return ManifestProperties{}, err
+	}
+
+	return ManifestProperties{
+		DeploymentName:            deploymentName,
+		ReleaseName:               ReleaseName,
+		AZ:                        c.Az,
+		VmType:                    c.VmType,
+		RootEphemeralVmType:       c.RootEphemeralVmType,
+		VmExtensions:              c.VmExtensions,
+		Network:                   c.Network,
+		DefaultUsername:           c.DefaultUsername,
+		DefaultPassword:           c.DefaultPassword,
+		StemcellOs:                c.StemcellOs,
+		StemcellVersion:           fmt.Sprintf(`"%s"`, stemcellVersion),
+		ReleaseVersion:            bwatsVersion,
+		MountEphemeralDisk:        c.MountEphemeralDisk,
+		SSHDisabledByDefault:      c.SSHDisabledByDefault,
+		SecurityComplianceApplied: c.SecurityComplianceApplied,
+		Expectations:              e,
+		ChecksFocus:               c.Checks.Focus,
+		ChecksSkip:                c.Checks.Skip,
+		UpdatesOfflineCatalog:     c.Updates.OfflineCatalogPath != "",
+		UpdatesExclude:            c.Updates.Exclude,
+	}, nil
+}
+
+// DeployWithManifestAndVars deploys a manifest with the variables set from the
+// config, plus any variables and ops files specific to that manifest.
+func (c *Config) DeployWithManifestAndVars(bosh *Bosh, deploymentName string, stemcellVersion string, bwatsVersion string, manifestPath string, vars map[string]interface{}, opsFilePaths ...string) error {
+	manifestProperties, err := c.ManifestProperties(deploymentName, stemcellVersion, bwatsVersion)
+	if err != nil {
+		return err
+	}
+
+	varsFilePath, err := manifestProperties.ToVarsFile(vars)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(varsFilePath) //nolint:errcheck
+
+	command := fmt.Sprintf("-d %s deploy %s", deploymentName, manifestPath)
+	for _, opsFilePath := range opsFilePaths {
+		command += fmt.Sprintf(" -o %s", opsFilePath)
+	}
+	command += fmt.Sprintf(" -l %s %s", varsFilePath, manifestProperties.ToVarsString())
+
+	return bosh.Run(command)
+}
+
+// MainManifestPath is the manifest of the main deployment of the suite, with
+// the check-multiple instance group, within root.
+func MainManifestPath(root string) string {
+	return filepath.Join(root, "assets", "manifest.yml")
+}
+
+// Deploy deploys the main manifest.
+func (c *Config) Deploy(bosh *Bosh, root string, deploymentName string, stemcellVersion string, bwatsVersion string) error {
+	// root-disk-as-ephemeral.yml only applies to the check-multiple instance group of manifest.yml
+	var opsFilePaths []string
+	if c.RootEphemeralVmType != "" {
+		opsFilePaths = append(opsFilePaths, filepath.Join(root, "assets", "root-disk-as-ephemeral.yml"))
+	}
+
+	return c.DeployWithManifestAndVars(bosh, deploymentName, stemcellVersion, bwatsVersion, MainManifestPath(root), nil, opsFilePaths...)
+}
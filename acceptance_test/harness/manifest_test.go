@@ -0,0 +1,109 @@
+package harness_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+var _ = Describe("ManifestProperties", func() {
+	var properties harness.ManifestProperties
+
+	BeforeEach(func() {
+		config, err := harness.ParseConfig([]byte(`{
+			"stemcell_os": "windows2019",
+			"az": "z1",
+			"vm_type": "large",
+			"network": "default",
+			"ssh_disabled_by_default": true,
+			"checks": {"focus": ["Verify-RandomPassword"]}
+		}`), filepath.Join(".."))
+		Expect(err).NotTo(HaveOccurred())
+
+		properties, err = config.ManifestProperties("bwats", "2019.1", "0.dev+1")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("passes the string variables with -v", func() {
+		vars := properties.ToVarsString()
+		Expect(vars).To(ContainSubstring("-v DeploymentName=bwats "))
+		Expect(vars).To(ContainSubstring("-v ReleaseName=bwats-release "))
+		Expect(vars).To(ContainSubstring(`-v StemcellVersion="2019.1" `))
+		Expect(vars).To(ContainSubstring("-v ReleaseVersion=0.dev+1 "))
+		Expect(vars).To(HaveSuffix("-v MountEphemeralDisk=false"))
+		Expect(vars).NotTo(ContainSubstring("RootEphemeralVmType"))
+	})
+
+	It("writes the check-system properties and extra variables to a vars file", func() {
+		path, err := properties.ToVarsFile(map[string]interface{}{"HWCPort": 8080})
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(path) //nolint:errcheck
+
+		body, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		var vars struct {
+			HWCPort               int `yaml:"HWCPort"`
+			CheckSystemProperties struct {
+				SSH struct {
+					DisabledByDefault bool `yaml:"disabled_by_default"`
+				} `yaml:"ssh"`
+				Checks struct {
+					Focus []string `yaml:"focus"`
+					Skip  []string `yaml:"skip"`
+				} `yaml:"checks"`
+				Password map[string]string `yaml:"password"`
+			} `yaml:"CheckSystemProperties"`
+		}
+		Expect(yaml.Unmarshal(body, &vars)).To(Succeed())
+
+		Expect(vars.HWCPort).To(Equal(8080))
+		Expect(vars.CheckSystemProperties.SSH.DisabledByDefault).To(BeTrue())
+		Expect(vars.CheckSystemProperties.Checks.Focus).To(ConsistOf("Verify-RandomPassword"))
+		Expect(vars.CheckSystemProperties.Checks.Skip).NotTo(BeNil())
+		Expect(vars.CheckSystemProperties.Password).To(BeEmpty())
+		Expect(string(body)).To(ContainSubstring("skip: []"))
+	})
+
+	It("writes the check-updates properties to the vars file", func() {
+		properties.UpdatesOfflineCatalog = true
+		properties.UpdatesExclude = []string{"KB5005463"}
+
+		path, err := properties.ToVarsFile(nil)
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(path) //nolint:errcheck
+
+		body, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		var vars struct {
+			CheckUpdatesProperties struct {
+				CheckUpdates struct {
+					OfflineCatalog bool     `yaml:"offline_catalog"`
+					Exclude        []string `yaml:"exclude"`
+				} `yaml:"check_updates"`
+			} `yaml:"CheckUpdatesProperties"`
+		}
+		Expect(yaml.Unmarshal(body, &vars)).To(Succeed())
+
+		Expect(vars.CheckUpdatesProperties.CheckUpdates.OfflineCatalog).To(BeTrue())
+		Expect(vars.CheckUpdatesProperties.CheckUpdates.Exclude).To(ConsistOf("KB5005463"))
+	})
+})
+
+var _ = Describe("Bosh", func() {
+	It("prefixes commands with the director and its credentials", func() {
+		bosh := &harness.Bosh{DirectorIP: "10.0.0.6", Client: "admin", ClientSecret: "secret", CertPath: "/tmp/ca.pem"}
+
+		Expect(bosh.Args("-d bwats vms")).To(Equal([]string{
+			"--ca-cert", "/tmp/ca.pem",
+			"-n", "-e", "10.0.0.6", "--client", "admin", "--client-secret", "secret",
+			"-d", "bwats", "vms",
+		}))
+	})
+})
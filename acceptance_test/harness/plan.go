@@ -0,0 +1,174 @@
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// readOnlyCommands are the bosh commands that run even in a dry run, as they
+// change neither the director nor the release.
+var readOnlyCommands = map[string]bool{
+	"login":           true,
+	"environment":     true,
+	"deployments":     true,
+	"releases":        true,
+	"inspect-release": true,
+	"stemcells":       true,
+	"instances":       true,
+	"vms":             true,
+	"manifest":        true,
+	"tasks":           true,
+	"task":            true,
+	"events":          true,
+	"cloud-config":    true,
+	"runtime-config":  true,
+	"configs":         true,
+	"interpolate":     true,
+}
+
+// Step is something a dry run would have done.
+type Step struct {
+	// Action is "bosh" for a bosh command, or "download" for a file the
+	// release needs.
+	Action  string `json:"action"`
+	Command string `json:"command"`
+	Dir     string `json:"dir,omitempty"`
+	// Manifest is the rendered manifest of a deploy command.
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// Plan records the steps of a dry run, in order.
+type Plan struct {
+	lock  sync.Mutex
+	Steps []Step `json:"steps"`
+}
+
+func NewPlan() *Plan {
+	return &Plan{Steps: []Step{}}
+}
+
+func (p *Plan) Add(step Step) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.Steps = append(p.Steps, step)
+}
+
+func (p *Plan) String() string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var b strings.Builder
+	for i, step := range p.Steps {
+		fmt.Fprintf(&b, "%d. %s %s\n", i+1, step.Action, step.Command)
+		if step.Dir != "" {
+			fmt.Fprintf(&b, "   in %s\n", step.Dir)
+		}
+		if step.Manifest != "" {
+			b.WriteString("   manifest:\n")
+			for _, line := range strings.Split(strings.TrimRight(step.Manifest, "\n"), "\n") {
+				fmt.Fprintf(&b, "     %s\n", line)
+			}
+		}
+	}
+	return b.String()
+}
+
+// Write writes the plan to plan.txt and plan.json in dir.
+func (p *Plan) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "plan.txt"), []byte(p.String()), 0644); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	body, err := json.MarshalIndent(p, "", "  ")
+	p.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "plan.json"), body, 0644)
+}
+
+// boshCommandName is the name of the bosh command in the arguments of a
+// command, after the -d flag if any.
+func boshCommandName(command []string) string {
+	for i := 0; i < len(command); i++ {
+		if command[i] == "-d" {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(command[i], "-") {
+			return command[i]
+		}
+	}
+	return ""
+}
+
+// planned reports whether a command is added to the plan instead of being run,
+// and adds it.
+func (b *Bosh) planned(command []string, dir string) (bool, error) {
+	if b.Plan == nil || readOnlyCommands[boshCommandName(command)] {
+		return false, nil
+	}
+
+	step := Step{
+		Action:  "bosh",
+		Command: b.redact(strings.Join(command, " ")),
+		Dir:     dir,
+	}
+
+	if boshCommandName(command) == "deploy" {
+		manifest, err := b.interpolate(command, dir)
+		if err != nil {
+			return true, err
+		}
+		step.Manifest = b.redact(manifest)
+	}
+
+	b.Plan.Add(step)
+	fmt.Fprintf(b.output(), "PLANNED %q\n", step.Command) //nolint:errcheck
+	return true, nil
+}
+
+// interpolate renders the manifest of a deploy command with its ops files and
+// variables, which the bosh CLI does without a director.
+func (b *Bosh) interpolate(command []string, dir string) (string, error) {
+	var args []string
+	for i, arg := range command {
+		if arg == "deploy" {
+			args = append([]string{"interpolate"}, command[i+1:]...)
+			break
+		}
+	}
+
+	cmd := exec.Command("bosh", args...)
+	cmd.Dir = dir
+	stdout, err := cmd.Output()
+	if err != nil {
+		var stderr []byte
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr = exitErr.Stderr
+		}
+		return "", fmt.Errorf("unable to render the manifest with %q: %s\n%s", strings.Join(cmd.Args, " "), err, stderr)
+	}
+	return string(stdout), nil
+}
+
+// redact removes the secrets of the config from a planned step.
+func (b *Bosh) redact(s string) string {
+	for _, secret := range b.Secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
@@ -0,0 +1,98 @@
+package harness_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+// fakeBoshInterpolate prints a manifest with the client secret for
+// `interpolate`, and the arguments of any other command.
+const fakeBoshInterpolate = `#!/bin/sh
+case "$1" in
+  interpolate) echo "name: dry-run"; echo "password: client-secret"; echo "args: $*" ;;
+  *) echo "args: $*" ;;
+esac
+`
+
+var _ = Describe("Plan", func() {
+	var bosh *harness.Bosh
+
+	BeforeEach(func() {
+		putFakeBoshOnPath(fakeBoshInterpolate)
+
+		bosh = &harness.Bosh{
+			DirectorIP:   "10.0.0.6",
+			Client:       "admin",
+			ClientSecret: "client-secret",
+			Secrets:      []string{"client-secret"},
+			Plan:         harness.NewPlan(),
+		}
+	})
+
+	It("runs the commands that do not change the director", func() {
+		stdout, err := bosh.RunInStdOut("-d windows-acceptance-test-1 vms", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(stdout)).To(ContainSubstring("-d windows-acceptance-test-1 vms"))
+
+		Expect(bosh.Plan.Steps).To(BeEmpty())
+	})
+
+	It("plans the other commands instead of running them", func() {
+		stdout, err := bosh.RunInStdOut("-d windows-acceptance-test-1 delete-deployment --force", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stdout).To(BeEmpty())
+
+		Expect(bosh.RunIn("upload-release", "/release")).To(Succeed())
+
+		Expect(bosh.Plan.Steps).To(Equal([]harness.Step{
+			{Action: "bosh", Command: "-d windows-acceptance-test-1 delete-deployment --force"},
+			{Action: "bosh", Command: "upload-release", Dir: "/release"},
+		}))
+	})
+
+	It("plans commands that run in the background instead of returning them", func() {
+		cmd, err := bosh.Command("-d windows-acceptance-test-1 ssh hwc/0 --opts=-N")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cmd).To(BeNil())
+
+		Expect(bosh.Plan.Steps).To(Equal([]harness.Step{
+			{Action: "bosh", Command: "-d windows-acceptance-test-1 ssh hwc/0 --opts=-N"},
+		}))
+	})
+
+	It("renders the manifest of a deploy, with the secrets redacted", func() {
+		Expect(bosh.Run("-d windows-acceptance-test-1 deploy manifest.yml -v Password=client-secret")).To(Succeed())
+
+		Expect(bosh.Plan.Steps).To(HaveLen(1))
+		step := bosh.Plan.Steps[0]
+		Expect(step.Command).To(Equal("-d windows-acceptance-test-1 deploy manifest.yml -v Password=<redacted>"))
+		Expect(step.Manifest).To(ContainSubstring("name: dry-run"))
+		Expect(step.Manifest).To(ContainSubstring("args: interpolate manifest.yml -v Password=<redacted>"))
+		Expect(step.Manifest).NotTo(ContainSubstring("client-secret"))
+	})
+
+	It("writes the plan as text and JSON", func() {
+		bosh.Plan.Add(harness.Step{Action: "download", Command: "https://example.com/LGPO.zip"})
+		Expect(bosh.Run("-d windows-acceptance-test-1 deploy manifest.yml")).To(Succeed())
+
+		dir := filepath.Join(GinkgoT().TempDir(), "plan")
+		Expect(bosh.Plan.Write(dir)).To(Succeed())
+
+		text, err := os.ReadFile(filepath.Join(dir, "plan.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(text)).To(HavePrefix("1. download https://example.com/LGPO.zip\n2. bosh -d windows-acceptance-test-1 deploy manifest.yml\n   manifest:\n     name: dry-run\n"))
+
+		body, err := os.ReadFile(filepath.Join(dir, "plan.json"))
+		Expect(err).NotTo(HaveOccurred())
+		var plan harness.Plan
+		Expect(json.Unmarshal(body, &plan)).To(Succeed())
+		Expect(plan.Steps).To(HaveLen(2))
+		Expect(plan.Steps[1].Manifest).To(ContainSubstring("name: dry-run"))
+	})
+})
@@ -0,0 +1,350 @@
+package harness
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/checks"
+)
+
+const (
+	ReleaseName = "bwats-release"
+
+	LgpoUrl  = "https://download.microsoft.com/download/8/5/C/85C25433-A1B0-4FFA-9429-7E023E7DA8D8/LGPO.zip"
+	lgpoFile = "LGPO.exe"
+)
+
+// ReleaseDir is the bwats-release within root, the directory of the suite.
+func ReleaseDir(root string) string {
+	return filepath.Join(root, "assets", "bwats-release")
+}
+
+// NewReleaseVersion is a unique dev version for the bwats-release.
+func NewReleaseVersion() string {
+	return fmt.Sprintf("0.dev+%d", TimestampInMs())
+}
+
+func TimestampInMs() int64 {
+	return time.Now().UTC().UnixNano() / int64(time.Millisecond)
+}
+
+// CreateRelease creates and uploads a new version of the bwats-release,
+// prepared by PrepareRelease, which it returns. With a
+// compiled_release_cache, a compiled release with the same jobs and packages
+// is uploaded instead, if one was exported by an earlier run for the stemcell,
+// see ReleaseCache.
+func CreateRelease(bosh *Bosh, root string, config *Config) (string, error) {
+	releaseDir, err := PrepareRelease(bosh, root, config)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(releaseDir) //nolint:errcheck
+
+	version := NewReleaseVersion()
+	cache, err := config.ReleaseCache()
+	if err != nil {
+		return "", err
+	}
+	if cache == nil || bosh.Plan != nil {
+		if err := bosh.RunIn(fmt.Sprintf("create-release --force --version %s", version), releaseDir); err != nil {
+			return "", err
+		}
+		if err := bosh.RunIn("upload-release", releaseDir); err != nil {
+			return "", err
+		}
+		return version, nil
+	}
+
+	return uploadWithCache(bosh, releaseDir, version, cache)
+}
+
+// PrepareRelease copies the bwats-release in root, see CopyRelease, and
+// prepares the copy for create-release, returning its directory, which the
+// caller removes. In the copy, the golang-windows package is rendered for the
+// Go version of the config, the sources of the slow-compile package are
+// generated for its workload and the check catalog is rendered into the
+// check-system job. The Go, LGPO, container base layer and WSUS offline
+// catalog blobs are added to it. The Go and LGPO zips are downloaded
+// unless they are found in root.
+func PrepareRelease(bosh *Bosh, root string, config *Config) (string, error) {
+	releaseDir, err := CopyRelease(ReleaseDir(root))
+	if err != nil {
+		return "", err
+	}
+	if err := prepareRelease(bosh, root, releaseDir, config); err != nil {
+		os.RemoveAll(releaseDir) //nolint:errcheck
+		return "", err
+	}
+	return releaseDir, nil
+}
+
+func prepareRelease(bosh *Bosh, root, releaseDir string, config *Config) error {
+	goToolchain := config.GoToolchain()
+	goZipPath, err := localOrDownload(bosh, filepath.Join(root, goToolchain.ZipFile()), "golang-", goToolchain.URL())
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(goZipPath); err == nil {
+		if err := goToolchain.Verify(goZipPath); err != nil {
+			return err
+		}
+	}
+	if err := goToolchain.Render(releaseDir, goZipPath); err != nil {
+		return err
+	}
+	if err := bosh.RunIn(fmt.Sprintf("add-blob %s %s", goZipPath, goToolchain.blobPath()), releaseDir); err != nil {
+		return err
+	}
+
+	if err := config.SlowCompile.Generate(releaseDir); err != nil {
+		return err
+	}
+
+	catalog, err := checks.LoadCatalog(filepath.Join(root, "assets", "checks", "catalog.yml"))
+	if err != nil {
+		return err
+	}
+	if err := catalog.Render(filepath.Join(releaseDir, "jobs", "check-system")); err != nil {
+		return err
+	}
+
+	lgpoZipPath, err := localOrDownload(bosh, filepath.Join(root, "LGPO.zip"), "lgpo-", LgpoUrl)
+	if err != nil {
+		return err
+	}
+	lgpoPath := fmt.Sprintf("%s/LGPO_30/%s", lgpoZipPath, lgpoFile)
+	if _, err := os.Stat(lgpoZipPath); err == nil {
+		lgpoPath, err = extractLgpo(lgpoZipPath)
+		if err != nil {
+			return err
+		}
+	}
+	if err := bosh.RunIn(fmt.Sprintf("add-blob %s lgpo/%s", lgpoPath, lgpoFile), releaseDir); err != nil {
+		return err
+	}
+
+	baseLayerPath, err := containerBaseLayerBlob(config)
+	if err != nil {
+		return err
+	}
+	if err := bosh.RunIn(fmt.Sprintf("add-blob %s container-base-layer/base-layer.zip", baseLayerPath), releaseDir); err != nil {
+		return err
+	}
+
+	catalogPath, err := offlineCatalogBlob(config)
+	if err != nil {
+		return err
+	}
+	if err := bosh.RunIn(fmt.Sprintf("add-blob %s wsus-offline-catalog/wsusscn2.cab", catalogPath), releaseDir); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// releaseBuildDirs are the directories of a release directory that hold
+// builds and blobs rather than sources, which CopyRelease leaves out.
+var releaseBuildDirs = map[string]bool{
+	"dev_releases": true,
+	".dev_builds":  true,
+	"blobs":        true,
+	".blobs":       true,
+}
+
+// CopyRelease copies the sources of a release directory, without its builds
+// and local blobs, to a new temporary directory which it returns. The harness
+// renders, adds blobs to and creates the copy, so that the files tracked in the
+// repository are left as they are.
+func CopyRelease(releaseDir string) (string, error) {
+	copyDir, err := os.MkdirTemp("", "bwats-release-")
+	if err != nil {
+		return "", err
+	}
+
+	err = filepath.Walk(releaseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(releaseDir, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && releaseBuildDirs[rel] {
+			return filepath.SkipDir
+		}
+
+		target := filepath.Join(copyDir, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+	if err != nil {
+		os.RemoveAll(copyDir) //nolint:errcheck
+		return "", fmt.Errorf("unable to copy %s: %s", releaseDir, err)
+	}
+	return copyDir, nil
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close() //nolint:errcheck
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close() //nolint:errcheck
+		return err
+	}
+	return out.Close()
+}
+
+// uploadWithCache creates a release tarball, and uploads it unless the cache
+// has a compiled release with the same jobs and packages, which it uploads
+// instead. It returns the version uploaded.
+func uploadWithCache(bosh *Bosh, releaseDir, version string, cache *ReleaseCache) (string, error) {
+	tarballDir, err := os.MkdirTemp("", "bwats-release-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tarballDir) //nolint:errcheck
+
+	tarball := filepath.Join(tarballDir, fmt.Sprintf("%s-%s.tgz", ReleaseName, version))
+	if err := bosh.RunIn(fmt.Sprintf("create-release --force --version %s --tarball %s", version, tarball), releaseDir); err != nil {
+		return "", err
+	}
+	manifest, err := ReadReleaseManifest(tarball)
+	if err != nil {
+		return "", err
+	}
+
+	if compiled, ok := cache.Lookup(manifest.ContentHash()); ok {
+		compiledManifest, err := ReadReleaseManifest(compiled)
+		if err != nil {
+			return "", err
+		}
+		if err := bosh.Run("upload-release " + compiled); err != nil {
+			return "", err
+		}
+		return compiledManifest.Version, nil
+	}
+
+	if err := bosh.Run("upload-release " + tarball); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// localOrDownload returns the path of a file in root, downloading it if it is
+// not there. A dry run plans the download and returns the URL instead.
+func localOrDownload(bosh *Bosh, localPath, prefix, url string) (string, error) {
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		return localPath, nil
+	}
+
+	if bosh.Plan != nil {
+		bosh.Plan.Add(Step{Action: "download", Command: url})
+		return url, nil
+	}
+	return DownloadFile(prefix, url)
+}
+
+func extractLgpo(lgpoZipPath string) (string, error) {
+	zipReader, err := zip.OpenReader(lgpoZipPath)
+	if err != nil {
+		return "", err
+	}
+	defer zipReader.Close() //nolint:errcheck
+
+	for _, zipFile := range zipReader.File {
+		if zipFile.Name != fmt.Sprintf("LGPO_30/%s", lgpoFile) {
+			continue
+		}
+
+		lgpo, err := os.CreateTemp("", lgpoFile)
+		if err != nil {
+			return "", err
+		}
+		defer lgpo.Close() //nolint:errcheck
+
+		zipRC, err := zipFile.Open()
+		if err != nil {
+			return "", err
+		}
+		defer zipRC.Close() //nolint:errcheck
+
+		if _, err := io.Copy(lgpo, zipRC); err != nil {
+			return "", err
+		}
+		return lgpo.Name(), nil
+	}
+
+	return "", fmt.Errorf("no LGPO_30/%s in %s", lgpoFile, lgpoZipPath)
+}
+
+// containerBaseLayerBlob returns the configured container base layer, or an
+// empty zip, so that the release can be created when none is configured.
+func containerBaseLayerBlob(config *Config) (string, error) {
+	if config.ContainerBaseLayerPath != "" {
+		return config.ContainerBaseLayerPath, nil
+	}
+
+	f, err := os.CreateTemp("", "base-layer-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck
+
+	return f.Name(), zip.NewWriter(f).Close()
+}
+
+// offlineCatalogBlob returns the configured offline catalog of Windows
+// updates, or an empty file when check-updates searches online.
+func offlineCatalogBlob(config *Config) (string, error) {
+	if config.Updates.OfflineCatalogPath != "" {
+		return config.Updates.OfflineCatalogPath, nil
+	}
+
+	f, err := os.CreateTemp("", "wsusscn2-*.cab")
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+func DownloadFile(prefix, sourceUrl string) (string, error) {
+	tempFile, err := os.CreateTemp("", prefix)
+	if err != nil {
+		return "", err
+	}
+	defer tempFile.Close() //nolint:errcheck
+
+	res, err := http.Get(sourceUrl)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close() //nolint:errcheck
+	if _, err := io.Copy(tempFile, res.Body); err != nil {
+		return "", err
+	}
+
+	return tempFile.Name(), nil
+}
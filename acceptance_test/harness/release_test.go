@@ -0,0 +1,60 @@
+package harness_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+var _ = Describe("CopyRelease", func() {
+	var releaseDir string
+
+	write := func(path, content string) {
+		Expect(os.MkdirAll(filepath.Join(releaseDir, filepath.Dir(path)), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(releaseDir, path), []byte(content), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		releaseDir = GinkgoT().TempDir()
+		write("config/blobs.yml", "lgpo/LGPO.exe:\n  size: 410088\n")
+		write("jobs/hwc/spec", "name: hwc\n")
+		write("packages/golang-windows/spec", "name: golang-windows\n")
+		write("src/Pester/Pester.psd1", "@{}\n")
+		write(".dev_builds/packages/golang-windows/index.yml", "builds: {}\n")
+		write("dev_releases/bwats-release/index.yml", "builds: {}\n")
+		write(".blobs/0a1b2c", "blob\n")
+		Expect(os.MkdirAll(filepath.Join(releaseDir, "blobs", "lgpo"), 0755)).To(Succeed())
+		Expect(os.Symlink(filepath.Join(releaseDir, ".blobs", "0a1b2c"), filepath.Join(releaseDir, "blobs", "lgpo", "LGPO.exe"))).To(Succeed())
+	})
+
+	It("copies the sources of the release, without its builds and blobs", func() {
+		copyDir, err := harness.CopyRelease(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(copyDir) //nolint:errcheck
+
+		for _, path := range []string{"config/blobs.yml", "jobs/hwc/spec", "packages/golang-windows/spec", "src/Pester/Pester.psd1"} {
+			original, err := os.ReadFile(filepath.Join(releaseDir, path))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.ReadFile(filepath.Join(copyDir, path))).To(Equal(original), path)
+		}
+		for _, dir := range []string{".dev_builds", "dev_releases", ".blobs", "blobs"} {
+			Expect(filepath.Join(copyDir, dir)).NotTo(BeAnExistingFile(), dir)
+		}
+	})
+
+	It("leaves the release untouched when the copy is rendered", func() {
+		copyDir, err := harness.CopyRelease(releaseDir)
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(copyDir) //nolint:errcheck
+
+		toolchain := harness.GoToolchain{Version: "1.22.5"}
+		Expect(toolchain.Render(copyDir, toolchain.URL())).To(Succeed())
+
+		Expect(os.ReadFile(filepath.Join(releaseDir, "config", "blobs.yml"))).To(Equal([]byte("lgpo/LGPO.exe:\n  size: 410088\n")))
+		Expect(os.ReadFile(filepath.Join(releaseDir, "packages", "golang-windows", "spec"))).To(Equal([]byte("name: golang-windows\n")))
+	})
+})
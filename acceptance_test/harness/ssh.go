@@ -0,0 +1,85 @@
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SSHResult is the output of a command run on an instance by
+// `bosh ssh --results`.
+type SSHResult struct {
+	Instance string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// sshFailedExitCode is the exit code of ssh itself failing, e.g. to connect,
+// rather than of the command it runs.
+const sshFailedExitCode = 255
+
+// SSH runs a command over ssh on an instance of a deployment, e.g.
+// "check-multiple/0", or on all its instances if instance is empty, and returns
+// the result on each. A command exiting non-zero is not an error, its exit code
+// is in its result; an error is returned when ssh fails, e.g. when it cannot
+// connect, whether bosh ssh reports it as a result or not.
+func SSH(bosh *Bosh, deployment, instance, command string) ([]SSHResult, error) {
+	args := []string{"-d", deployment, "ssh"}
+	if instance != "" {
+		args = append(args, instance)
+	}
+	args = append(args, "--results", "--command="+command, "--json")
+
+	stdout, err := bosh.RunArgsIn(args, "")
+	if bosh.Plan != nil {
+		return nil, err
+	}
+
+	results, parseErr := ParseSSHResults(stdout)
+	if parseErr != nil || len(results) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		return nil, fmt.Errorf("no results for %q on %s", command, deployment)
+	}
+	for _, result := range results {
+		if result.ExitCode == sshFailedExitCode {
+			return nil, fmt.Errorf("ssh to %s failed: %s", result.Instance, result.Stderr)
+		}
+	}
+	return results, nil
+}
+
+// ParseSSHResults parses the table printed by `bosh ssh --results --json`.
+func ParseSSHResults(output []byte) ([]SSHResult, error) {
+	var parsed struct {
+		Tables []struct {
+			Rows []map[string]string
+		}
+	}
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		return nil, fmt.Errorf("unable to parse the results of bosh ssh: %s", err)
+	}
+
+	var results []SSHResult
+	for _, table := range parsed.Tables {
+		for _, row := range table.Rows {
+			exitCode, err := strconv.Atoi(strings.TrimSpace(row["exit_code"]))
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse the exit code of %s: %s", row["instance"], err)
+			}
+			results = append(results, SSHResult{
+				Instance: row["instance"],
+				Stdout:   strings.TrimSpace(row["stdout"]),
+				Stderr:   strings.TrimSpace(row["stderr"]),
+				ExitCode: exitCode,
+			})
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,90 @@
+package harness_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+// fakeBoshSSH runs the --command of `bosh ssh --results` on two instances: it
+// echoes the command, exits with the code after "exit", and fails to connect
+// when the command is "unreachable" or "refused", without or with results.
+const fakeBoshSSH = `#!/bin/sh
+for arg in "$@"; do
+  case "$arg" in --command=*) command="${arg#--command=}" ;; esac
+done
+case "$command" in
+  unreachable)
+    echo "Running SSH: exit status 255" >&2
+    exit 1
+    ;;
+  refused)
+    cat <<EOF
+{"Tables": [{"Rows": [
+  {"instance": "check-multiple/0a1b", "stdout": "", "stderr": "ssh: connect to host 10.0.1.5 port 22: Connection refused\\r\\n", "exit_code": "255"}
+]}]}
+EOF
+    exit 1
+    ;;
+  exit\ *)
+    code="${command#exit }"
+    ;;
+  *)
+    code=0
+    ;;
+esac
+cat <<EOF
+{"Tables": [{"Rows": [
+  {"instance": "check-multiple/0a1b", "stdout": "$command\r\n", "stderr": "", "exit_code": "$code"},
+  {"instance": "check-multiple/2c3d", "stdout": "$command\r\n", "stderr": "", "exit_code": "$code"}
+]}]}
+EOF
+[ "$code" = 0 ]
+`
+
+var _ = Describe("SSH", func() {
+	var bosh *harness.Bosh
+
+	BeforeEach(func() {
+		putFakeBoshOnPath(fakeBoshSSH)
+
+		bosh = &harness.Bosh{DirectorIP: "10.0.0.6", Client: "admin", ClientSecret: "secret"}
+	})
+
+	It("returns the output of a command with spaces on each instance", func() {
+		results, err := harness.SSH(bosh, "windows-acceptance-test-1", "", "echo bwats ssh")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(Equal([]harness.SSHResult{
+			{Instance: "check-multiple/0a1b", Stdout: "echo bwats ssh"},
+			{Instance: "check-multiple/2c3d", Stdout: "echo bwats ssh"},
+		}))
+	})
+
+	It("returns the exit code of a failing command rather than an error", func() {
+		results, err := harness.SSH(bosh, "windows-acceptance-test-1", "check-multiple/0", "exit 7")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].ExitCode).To(Equal(7))
+	})
+
+	It("fails when bosh ssh does not return results", func() {
+		_, err := harness.SSH(bosh, "windows-acceptance-test-1", "check-multiple/0", "unreachable")
+		Expect(err).To(MatchError(ContainSubstring("Running SSH: exit status 255")))
+	})
+
+	It("fails when ssh cannot connect to an instance", func() {
+		_, err := harness.SSH(bosh, "windows-acceptance-test-1", "check-multiple/0", "refused")
+		Expect(err).To(MatchError("ssh to check-multiple/0a1b failed: ssh: connect to host 10.0.1.5 port 22: Connection refused"))
+	})
+
+	It("plans the command in a dry run", func() {
+		bosh.Plan = harness.NewPlan()
+
+		results, err := harness.SSH(bosh, "windows-acceptance-test-1", "check-multiple/0", "echo bwats ssh")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(BeEmpty())
+		Expect(bosh.Plan.Steps).To(ConsistOf(HaveField("Command",
+			"-d windows-acceptance-test-1 ssh check-multiple/0 --results --command=echo bwats ssh --json")))
+	})
+})
@@ -0,0 +1,73 @@
+package harness
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// stemcellUploadRetryInterval is how long to wait before uploading a stemcell
+// again: the ami may not be immediately available.
+const stemcellUploadRetryInterval = 3 * time.Minute
+
+type StemcellYML struct {
+	Version         string `yaml:"version"`
+	Name            string `yaml:"name"`
+	OperatingSystem string `yaml:"operating_system"`
+}
+
+// FetchStemcellInfo reads the name and version from the stemcell.MF of a
+// stemcell tarball.
+func FetchStemcellInfo(stemcellPath string) (StemcellYML, error) {
+	var stemcellInfo StemcellYML
+	tempDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return stemcellInfo, err
+	}
+	defer os.RemoveAll(tempDir) //nolint:errcheck
+
+	cmd := exec.Command("tar", "xf", stemcellPath, "-C", tempDir, "stemcell.MF")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return stemcellInfo, fmt.Errorf("Non-zero exit code for cmd %q: %s\nOUTPUT:\n%s\n",
+			strings.Join(cmd.Args, " "), err, output)
+	}
+
+	stemcellMF, err := os.ReadFile(filepath.Join(tempDir, "stemcell.MF"))
+	if err != nil {
+		return stemcellInfo, err
+	}
+
+	if err := yaml.Unmarshal(stemcellMF, &stemcellInfo); err != nil {
+		return stemcellInfo, err
+	}
+	if stemcellInfo.Version == "" {
+		return stemcellInfo, fmt.Errorf("no version in the stemcell.MF of %s", stemcellPath)
+	}
+
+	return stemcellInfo, nil
+}
+
+// UploadStemcell uploads the stemcell matching a glob, retrying until it
+// succeeds. If it is actually broken, the timeout of the pipeline kicks in.
+func UploadStemcell(bosh *Bosh, stemcellPath string) error {
+	matches, err := filepath.Glob(stemcellPath)
+	if err != nil {
+		return err
+	}
+	if len(matches) != 1 {
+		return fmt.Errorf("stemcell path %q matches %d files, expected 1", stemcellPath, len(matches))
+	}
+
+	for {
+		err = bosh.Run(fmt.Sprintf("upload-stemcell %s", matches[0]))
+		if err == nil {
+			return nil
+		}
+		time.Sleep(stemcellUploadRetryInterval)
+	}
+}
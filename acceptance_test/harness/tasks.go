@@ -0,0 +1,108 @@
+package harness
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const compilingStage = "Compiling packages"
+
+// TaskEvent is an entry of the event log of a director task, as printed by
+// `bosh task --event`.
+type TaskEvent struct {
+	Time  int64  `json:"time"`
+	Stage string `json:"stage"`
+	// Task is e.g. the name/fingerprint of the package being compiled.
+	Task  string `json:"task"`
+	State string `json:"state"`
+}
+
+// LastDeployTask returns the id of the latest successful deploy of a
+// deployment.
+func LastDeployTask(bosh *Bosh, deployment string) (string, error) {
+	rows, err := bosh.RunTable(fmt.Sprintf("-d %s tasks --recent=50", deployment))
+	if err != nil {
+		return "", err
+	}
+
+	for _, row := range rows {
+		if row["description"] == "create deployment" && row["state"] == "done" {
+			return row["id"], nil
+		}
+	}
+	return "", fmt.Errorf("no successful deploy of %s in its recent tasks", deployment)
+}
+
+// TaskEvents returns the event log of a task.
+func TaskEvents(bosh *Bosh, id string) ([]TaskEvent, error) {
+	stdout, err := bosh.RunInStdOut(fmt.Sprintf("task %s --event", id), "")
+	if err != nil {
+		return nil, err
+	}
+	return ParseTaskEvents(stdout)
+}
+
+// ParseTaskEvents parses the events in the output of `bosh task --event`, one
+// JSON object per line, skipping the other lines the CLI prints.
+func ParseTaskEvents(output []byte) ([]TaskEvent, error) {
+	var events []TaskEvent
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var event TaskEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			return nil, fmt.Errorf("unable to parse task event %q: %s", line, err)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// CompileDurations are the compile times of the packages compiled in a task,
+// by package name.
+func CompileDurations(events []TaskEvent) map[string]time.Duration {
+	started := map[string]int64{}
+	durations := map[string]time.Duration{}
+	for _, event := range events {
+		if event.Stage != compilingStage {
+			continue
+		}
+
+		switch event.State {
+		case "started":
+			started[event.Task] = event.Time
+		case "finished":
+			if start, ok := started[event.Task]; ok {
+				name, _, _ := strings.Cut(event.Task, "/")
+				durations[name] = time.Duration(event.Time-start) * time.Second
+			}
+		}
+	}
+	return durations
+}
+
+// PackageCompileTime is the compile time of a package in the latest deploy of
+// a deployment. It is not found if the package was already compiled.
+func PackageCompileTime(bosh *Bosh, deployment, name string) (time.Duration, bool, error) {
+	id, err := LastDeployTask(bosh, deployment)
+	if err != nil {
+		return 0, false, err
+	}
+
+	events, err := TaskEvents(bosh, id)
+	if err != nil {
+		return 0, false, err
+	}
+
+	d, ok := CompileDurations(events)[name]
+	return d, ok, nil
+}
@@ -0,0 +1,47 @@
+package harness_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+var _ = Describe("Task events", func() {
+	const output = `Using environment '10.0.0.6' as client 'admin'
+
+Task 42
+
+{"time":1570000000,"stage":"Preparing deployment","tags":[],"total":1,"task":"Preparing deployment","index":1,"state":"started","progress":0}
+{"time":1570000010,"stage":"Compiling packages","tags":[],"total":2,"task":"golang-windows/6f1c2a","index":1,"state":"started","progress":0}
+{"time":1570000012,"stage":"Compiling packages","tags":[],"total":2,"task":"slow-compile/9d2e4b","index":2,"state":"started","progress":0}
+{"time":1570000040,"stage":"Compiling packages","tags":[],"total":2,"task":"golang-windows/6f1c2a","index":1,"state":"finished","progress":100}
+{"time":1570000102,"stage":"Compiling packages","tags":[],"total":2,"task":"slow-compile/9d2e4b","index":2,"state":"finished","progress":100}
+
+Task 42 done
+`
+
+	It("parses the events, skipping the other lines", func() {
+		events, err := harness.ParseTaskEvents([]byte(output))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(HaveLen(5))
+		Expect(events[0]).To(Equal(harness.TaskEvent{Time: 1570000000, Stage: "Preparing deployment", Task: "Preparing deployment", State: "started"}))
+	})
+
+	It("returns the compile time of each package", func() {
+		events, err := harness.ParseTaskEvents([]byte(output))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(harness.CompileDurations(events)).To(Equal(map[string]time.Duration{
+			"golang-windows": 30 * time.Second,
+			"slow-compile":   90 * time.Second,
+		}))
+	})
+
+	It("fails on a malformed event", func() {
+		_, err := harness.ParseTaskEvents([]byte(`{"time": "now"}`))
+		Expect(err).To(MatchError(ContainSubstring("unable to parse task event")))
+	})
+})
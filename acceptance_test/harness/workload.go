@@ -0,0 +1,173 @@
+package harness
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultWorkloadFiles          = 20
+	DefaultWorkloadFanOut         = 3
+	DefaultWorkloadTargetDuration = "1m"
+
+	workloadPackage   = "slow-compile"
+	workloadGenerated = "Code generated by the acceptance test harness for slow_compile in the test config. DO NOT EDIT."
+
+	// workloadFunctionsPerSecond is roughly how many generated functions the
+	// compilation VMs of the pipeline compile per second. The compile time of
+	// a workload can only approach its target, as it depends on the VM.
+	workloadFunctionsPerSecond = 300
+)
+
+// Workload is the synthetic Go program of the slow-compile package, sized to
+// take a while to compile on the compilation VMs.
+type Workload struct {
+	// Files is the number of generated files, each in its own package.
+	Files int `json:"files"`
+	// FanOut is the number of packages each package imports, among those
+	// generated before it.
+	FanOut int `json:"fan_out"`
+	// TargetDuration is the compile time the workload is sized for, e.g. 1m.
+	TargetDuration string `json:"target_duration"`
+}
+
+func (w Workload) Validate() error {
+	if w.Files < 1 {
+		return fmt.Errorf("slow_compile.files must be at least 1, got %d", w.Files)
+	}
+	if w.FanOut < 0 {
+		return fmt.Errorf("slow_compile.fan_out must not be negative, got %d", w.FanOut)
+	}
+	if _, err := w.duration(); err != nil {
+		return fmt.Errorf("slow_compile.target_duration: %s", err)
+	}
+	return nil
+}
+
+func (w Workload) duration() (time.Duration, error) {
+	return time.ParseDuration(w.TargetDuration)
+}
+
+// FunctionsPerFile is the number of functions in each file for the compile
+// time to approach the target.
+func (w Workload) FunctionsPerFile() int {
+	d, err := w.duration()
+	if err != nil || w.Files < 1 {
+		return 1
+	}
+
+	functions := int(d.Seconds()*workloadFunctionsPerSecond) / w.Files
+	if functions < 1 {
+		return 1
+	}
+	return functions
+}
+
+// Generate replaces the sources of the slow-compile package in the release
+// with the workload: a main package importing every generated package, which
+// prints what it ran when run.
+func (w Workload) Generate(releaseDir string) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+
+	srcDir := filepath.Join(releaseDir, "src", workloadPackage)
+	if err := os.RemoveAll(srcDir); err != nil {
+		return err
+	}
+
+	functions := w.FunctionsPerFile()
+	for i := 0; i < w.Files; i++ {
+		dir := filepath.Join(srcDir, "gen", workloadPackageName(i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, workloadPackageName(i)+".go")
+		if err := os.WriteFile(path, []byte(w.generatePackage(i, functions)), 0644); err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(filepath.Join(srcDir, "main.go"), []byte(w.generateMain(functions)), 0644)
+}
+
+func workloadPackageName(i int) string {
+	return fmt.Sprintf("p%03d", i)
+}
+
+// workloadImports are the packages package i imports: the FanOut packages
+// generated right before it.
+func (w Workload) workloadImports(i int) []int {
+	var imports []int
+	for j := i - 1; j >= 0 && len(imports) < w.FanOut; j-- {
+		imports = append(imports, j)
+	}
+	return imports
+}
+
+func (w Workload) generatePackage(i, functions int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s\n\npackage %s\n", workloadGenerated, workloadPackageName(i))
+
+	imports := w.workloadImports(i)
+	if len(imports) != 0 {
+		b.WriteString("\nimport (\n")
+		for k := len(imports) - 1; k >= 0; k-- {
+			j := imports[k]
+			fmt.Fprintf(&b, "\t\"%s/gen/%s\"\n", workloadPackage, workloadPackageName(j))
+		}
+		b.WriteString(")\n")
+	}
+
+	// Each function is large enough for the compiler to spend some time on
+	// it, and calls one of the imported packages so that they are linked.
+	for f := 0; f < functions; f++ {
+		fmt.Fprintf(&b, `
+func F%d(x uint64) uint64 {
+	var a [16]uint64
+	for i := range a {
+		a[i] = x*uint64(i+%d) ^ (x >> uint(i%%7))
+	}
+	s := uint64(%d)
+	for i, v := range a {
+		switch {
+		case v%%3 == uint64(i%%3):
+			s += v
+		case v%%5 == 0:
+			s ^= v << 1
+		default:
+			s = s*31 + v
+		}
+	}
+`, f, f+1, i)
+		if len(imports) != 0 {
+			fmt.Fprintf(&b, "\ts += %s.F%d(s)\n", workloadPackageName(imports[f%len(imports)]), f)
+		}
+		b.WriteString("\treturn s\n}\n")
+	}
+
+	b.WriteString("\n// Run calls every function of the package.\nfunc Run(x uint64) uint64 {\n")
+	for f := 0; f < functions; f++ {
+		fmt.Fprintf(&b, "\tx = F%d(x)\n", f)
+	}
+	b.WriteString("\treturn x\n}\n")
+
+	return b.String()
+}
+
+func (w Workload) generateMain(functions int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s\n\n// slow-compile is a synthetic program that takes a while to compile.\npackage main\n\nimport (\n\t\"fmt\"\n\n", workloadGenerated)
+	for i := 0; i < w.Files; i++ {
+		fmt.Fprintf(&b, "\t\"%s/gen/%s\"\n", workloadPackage, workloadPackageName(i))
+	}
+	b.WriteString(")\n\nfunc main() {\n\tx := uint64(1)\n")
+	for i := 0; i < w.Files; i++ {
+		fmt.Fprintf(&b, "\tx = %s.Run(x)\n", workloadPackageName(i))
+	}
+	fmt.Fprintf(&b, "\tfmt.Printf(\"slow-compile ran %d packages of %d functions: %%d\\n\", x)\n}\n", w.Files, functions)
+	return b.String()
+}
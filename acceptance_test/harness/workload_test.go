@@ -0,0 +1,65 @@
+package harness_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+var _ = Describe("Workload", func() {
+	var (
+		releaseDir string
+		workload   harness.Workload
+	)
+
+	BeforeEach(func() {
+		releaseDir = GinkgoT().TempDir()
+		workload = harness.Workload{Files: 4, FanOut: 2, TargetDuration: "2s"}
+	})
+
+	It("sizes the files for the target duration", func() {
+		Expect(workload.FunctionsPerFile()).To(Equal(150))
+
+		workload.TargetDuration = "1ms"
+		Expect(workload.FunctionsPerFile()).To(Equal(1))
+	})
+
+	It("generates a package per file, each importing the packages before it", func() {
+		stale := filepath.Join(releaseDir, "src", "slow-compile", "main_old.go")
+		Expect(os.MkdirAll(filepath.Dir(stale), 0755)).To(Succeed())
+		Expect(os.WriteFile(stale, nil, 0644)).To(Succeed())
+
+		Expect(workload.Generate(releaseDir)).To(Succeed())
+
+		srcDir := filepath.Join(releaseDir, "src", "slow-compile")
+		Expect(stale).NotTo(BeAnExistingFile())
+
+		main, err := os.ReadFile(filepath.Join(srcDir, "main.go"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(main)).To(ContainSubstring(`"slow-compile/gen/p003"`))
+		Expect(string(main)).To(ContainSubstring("slow-compile ran 4 packages of 150 functions"))
+
+		first, err := os.ReadFile(filepath.Join(srcDir, "gen", "p000", "p000.go"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(first)).NotTo(ContainSubstring("import"))
+		Expect(string(first)).To(ContainSubstring("func F149(x uint64) uint64 {"))
+
+		last, err := os.ReadFile(filepath.Join(srcDir, "gen", "p003", "p003.go"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(last)).To(ContainSubstring("import (\n\t\"slow-compile/gen/p001\"\n\t\"slow-compile/gen/p002\"\n)\n"))
+		Expect(string(last)).NotTo(ContainSubstring(`"slow-compile/gen/p000"`))
+	})
+
+	It("rejects an invalid workload", func() {
+		workload.Files = 0
+		Expect(workload.Generate(releaseDir)).To(MatchError(ContainSubstring("slow_compile.files")))
+
+		workload.Files = 1
+		workload.TargetDuration = "soon"
+		Expect(workload.Validate()).To(MatchError(ContainSubstring("slow_compile.target_duration")))
+	})
+})
@@ -0,0 +1,89 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+// freeLocalPort returns a port that nothing listens on locally.
+func freeLocalPort() int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer listener.Close() //nolint:errcheck
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+var _ = Describe("Hosted Web Core", func() {
+	var (
+		hwcDeploymentName string
+		tunnel            *gexec.Session
+	)
+
+	BeforeEach(func() {
+		if testConfig.SSHDisabledByDefault {
+			Skip("Skipping HWC test - it reaches the app through bosh ssh, which is disabled by default")
+		}
+
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		hwcDeploymentName = fmt.Sprintf("windows-acceptance-test-hwc-%d", getTimestampInMs())
+
+		err = testConfig.deployWithManifestAndVars(boshCommand, hwcDeploymentName, stemcellVersion, releaseVersion,
+			filepath.Join(pwd, "assets", "hwc-manifest.yml"),
+			map[string]interface{}{"HWCPort": testConfig.HWCPort})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if tunnel != nil {
+			stopSession(tunnel)
+			tunnel = nil
+		}
+
+		if testConfig.SkipCleanup || hwcDeploymentName == "" {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", hwcDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("serves an ASP.NET app", func() {
+		localPort := freeLocalPort()
+		tunnel = boshCommand.Start(fmt.Sprintf("-d %s ssh hwc/0 --opts=-N --opts=-L --opts=%d:localhost:%d",
+			hwcDeploymentName, localPort, testConfig.HWCPort))
+
+		// HWCServer only answers requests for the host localhost.
+		url := fmt.Sprintf("http://localhost:%d/bwats-hwc/Default.aspx", localPort)
+		client := &http.Client{Timeout: 30 * time.Second}
+
+		var body string
+		Eventually(func(g Gomega) {
+			if tunnel.ExitCode() != -1 {
+				StopTrying("bosh ssh exited").Now()
+			}
+
+			res, err := client.Get(url)
+			g.Expect(err).NotTo(HaveOccurred())
+			defer res.Body.Close() //nolint:errcheck
+
+			content, err := io.ReadAll(res.Body)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(res.StatusCode).To(Equal(http.StatusOK), string(content))
+			body = string(content)
+		}, 5*time.Minute, 10*time.Second).Should(Succeed())
+
+		Expect(body).To(MatchRegexp(`bwats hwc app running on \.NET \d+\.\d+`))
+	})
+})
@@ -0,0 +1,200 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "+"
+	Removed ChangeKind = "-"
+	Changed ChangeKind = "~"
+)
+
+type Change struct {
+	Kind ChangeKind
+	Key  string
+	Old  string
+	New  string
+}
+
+type Category struct {
+	Name    string
+	Changes []Change
+}
+
+// Report lists the changes between two inventories, by category.
+type Report struct {
+	OldVersion string
+	NewVersion string
+	Categories []Category
+}
+
+// Diff compares the inventories of two stemcells. Only categories with changes
+// are included in the report.
+func Diff(before, after *Inventory) Report {
+	report := Report{OldVersion: before.StemcellVersion, NewVersion: after.StemcellVersion}
+
+	add := func(name string, before, after map[string]string) {
+		if changes := diffEntries(before, after); len(changes) != 0 {
+			report.Categories = append(report.Categories, Category{Name: name, Changes: changes})
+		}
+	}
+
+	add("OS version", map[string]string{"version": before.OSVersion}, map[string]string{"version": after.OSVersion})
+	add("Windows features", features(before), features(after))
+	add("Hotfixes", hotfixes(before), hotfixes(after))
+	add("Services", services(before), services(after))
+	add("Local users", users(before), users(after))
+	add("Certificates", certificates(before), certificates(after))
+	add("Firewall rules", firewallRules(before), firewallRules(after))
+	add("Scheduled tasks", scheduledTasks(before), scheduledTasks(after))
+	add("Installed programs", programs(before), programs(after))
+	add(`C:\var\vcap\bosh\bin`, boshBinaries(before), boshBinaries(after))
+
+	return report
+}
+
+func (r Report) HasChanges() bool {
+	return len(r.Categories) != 0
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Changes from stemcell %s to %s\n", r.OldVersion, r.NewVersion)
+
+	if !r.HasChanges() {
+		b.WriteString("\nNo changes\n")
+		return b.String()
+	}
+
+	for _, category := range r.Categories {
+		fmt.Fprintf(&b, "\n%s (%d)\n", category.Name, len(category.Changes))
+		for _, c := range category.Changes {
+			switch c.Kind {
+			case Added:
+				fmt.Fprintf(&b, "  + %s%s\n", c.Key, detail(c.New))
+			case Removed:
+				fmt.Fprintf(&b, "  - %s%s\n", c.Key, detail(c.Old))
+			case Changed:
+				fmt.Fprintf(&b, "  ~ %s: %s -> %s\n", c.Key, c.Old, c.New)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func detail(value string) string {
+	if value == "" {
+		return ""
+	}
+	return " (" + value + ")"
+}
+
+func diffEntries(before, after map[string]string) []Change {
+	var changes []Change
+
+	for key, oldValue := range before {
+		newValue, ok := after[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: Removed, Key: key, Old: oldValue})
+		case oldValue != newValue:
+			changes = append(changes, Change{Kind: Changed, Key: key, Old: oldValue, New: newValue})
+		}
+	}
+	for key, newValue := range after {
+		if _, ok := before[key]; !ok {
+			changes = append(changes, Change{Kind: Added, Key: key, New: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+func features(i *Inventory) map[string]string {
+	entries := map[string]string{}
+	for _, f := range i.Features {
+		entries[f] = ""
+	}
+	return entries
+}
+
+func hotfixes(i *Inventory) map[string]string {
+	entries := map[string]string{}
+	for _, h := range i.Hotfixes {
+		entries[h.ID] = h.Description
+	}
+	return entries
+}
+
+func services(i *Inventory) map[string]string {
+	entries := map[string]string{}
+	for _, s := range i.Services {
+		entries[s.Name] = fmt.Sprintf("%s, %s", s.StartType, s.Status)
+	}
+	return entries
+}
+
+func users(i *Inventory) map[string]string {
+	entries := map[string]string{}
+	for _, u := range i.Users {
+		if u.Enabled {
+			entries[u.Name] = "enabled"
+		} else {
+			entries[u.Name] = "disabled"
+		}
+	}
+	return entries
+}
+
+func certificates(i *Inventory) map[string]string {
+	entries := map[string]string{}
+	for _, c := range i.Certificates {
+		entries[c.Store+`\`+c.Thumbprint] = c.Subject
+	}
+	return entries
+}
+
+func firewallRules(i *Inventory) map[string]string {
+	entries := map[string]string{}
+	for _, r := range i.FirewallRules {
+		entries[r.Name] = fmt.Sprintf("enabled: %s, %s, %s", r.Enabled, r.Direction, r.Action)
+	}
+	return entries
+}
+
+func scheduledTasks(i *Inventory) map[string]string {
+	entries := map[string]string{}
+	for _, t := range i.ScheduledTasks {
+		entries[t.Path+t.Name] = t.State
+	}
+	return entries
+}
+
+func programs(i *Inventory) map[string]string {
+	entries := map[string]string{}
+	for _, p := range i.Programs {
+		key := p.Name
+		if p.Architecture != "" {
+			key = p.Architecture + `\` + p.Name
+		}
+		entries[key] = p.Version
+	}
+	return entries
+}
+
+func boshBinaries(i *Inventory) map[string]string {
+	entries := map[string]string{}
+	for _, f := range i.BoshBinaries {
+		entries[f.Name] = strings.TrimSpace(fmt.Sprintf("%s sha256:%s", f.Version, f.SHA256))
+	}
+	return entries
+}
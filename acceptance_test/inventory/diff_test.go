@@ -0,0 +1,112 @@
+package inventory_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/inventory"
+)
+
+var _ = Describe("Diff", func() {
+	var before, after *inventory.Inventory
+
+	BeforeEach(func() {
+		var err error
+		before, err = inventory.Parse([]byte(`{
+			"stemcell_version": "2019.70",
+			"os_version": "10.0.17763.0",
+			"features": ["Containers", "FS-FileServer"],
+			"hotfixes": [{"id": "KB5005112", "description": "Security Update"}],
+			"services": [
+				{"name": "sshd", "start_type": "Automatic", "status": "Running"},
+				{"name": "WinRM", "start_type": "Manual", "status": "Stopped"}
+			],
+			"users": [{"name": "Administrator", "enabled": true}],
+			"certificates": [{"store": "Root", "thumbprint": "AB12", "subject": "CN=Old Root"}],
+			"programs": [
+				{"name": "Microsoft Visual C++ 2015-2019 Redistributable", "architecture": "x64", "version": "14.28.29913"},
+				{"name": "Microsoft Visual C++ 2015-2019 Redistributable", "architecture": "x86", "version": "14.28.29913"}
+			],
+			"bosh_binaries": [{"name": "bosh-agent.exe", "version": "", "sha256": "aaaa"}]
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+
+		after, err = inventory.Parse([]byte(`{
+			"stemcell_version": "2019.71",
+			"os_version": "10.0.17763.0",
+			"features": ["Containers"],
+			"hotfixes": [
+				{"id": "KB5005112", "description": "Security Update"},
+				{"id": "KB5006672", "description": "Security Update"}
+			],
+			"services": [
+				{"name": "sshd", "start_type": "Disabled", "status": "Stopped"},
+				{"name": "WinRM", "start_type": "Manual", "status": "Stopped"}
+			],
+			"users": [{"name": "Administrator", "enabled": true}],
+			"certificates": [{"store": "Root", "thumbprint": "CD34", "subject": "CN=New Root"}],
+			"programs": [
+				{"name": "Microsoft Visual C++ 2015-2019 Redistributable", "architecture": "x64", "version": "14.29.30133"},
+				{"name": "Microsoft Visual C++ 2015-2019 Redistributable", "architecture": "x86", "version": "14.28.29913"}
+			],
+			"bosh_binaries": [{"name": "bosh-agent.exe", "version": "", "sha256": "bbbb"}]
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reports added, removed and changed entries by category", func() {
+		report := inventory.Diff(before, after)
+
+		Expect(report.Categories).To(Equal([]inventory.Category{
+			{Name: "Windows features", Changes: []inventory.Change{
+				{Kind: inventory.Removed, Key: "FS-FileServer"},
+			}},
+			{Name: "Hotfixes", Changes: []inventory.Change{
+				{Kind: inventory.Added, Key: "KB5006672", New: "Security Update"},
+			}},
+			{Name: "Services", Changes: []inventory.Change{
+				{Kind: inventory.Changed, Key: "sshd", Old: "Automatic, Running", New: "Disabled, Stopped"},
+			}},
+			{Name: "Certificates", Changes: []inventory.Change{
+				{Kind: inventory.Removed, Key: `Root\AB12`, Old: "CN=Old Root"},
+				{Kind: inventory.Added, Key: `Root\CD34`, New: "CN=New Root"},
+			}},
+			{Name: "Installed programs", Changes: []inventory.Change{
+				{Kind: inventory.Changed, Key: `x64\Microsoft Visual C++ 2015-2019 Redistributable`, Old: "14.28.29913", New: "14.29.30133"},
+			}},
+			{Name: `C:\var\vcap\bosh\bin`, Changes: []inventory.Change{
+				{Kind: inventory.Changed, Key: "bosh-agent.exe", Old: "sha256:aaaa", New: "sha256:bbbb"},
+			}},
+		}))
+	})
+
+	It("prints a categorized report", func() {
+		Expect(inventory.Diff(before, after).String()).To(Equal(`Changes from stemcell 2019.70 to 2019.71
+
+Windows features (1)
+  - FS-FileServer
+
+Hotfixes (1)
+  + KB5006672 (Security Update)
+
+Services (1)
+  ~ sshd: Automatic, Running -> Disabled, Stopped
+
+Certificates (2)
+  - Root\AB12 (CN=Old Root)
+  + Root\CD34 (CN=New Root)
+
+Installed programs (1)
+  ~ x64\Microsoft Visual C++ 2015-2019 Redistributable: 14.28.29913 -> 14.29.30133
+
+C:\var\vcap\bosh\bin (1)
+  ~ bosh-agent.exe: sha256:aaaa -> sha256:bbbb
+`))
+	})
+
+	It("reports when nothing has changed", func() {
+		report := inventory.Diff(before, before)
+		Expect(report.HasChanges()).To(BeFalse())
+		Expect(report.String()).To(HaveSuffix("\nNo changes\n"))
+	})
+})
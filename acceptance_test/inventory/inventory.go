@@ -0,0 +1,97 @@
+// Package inventory models the snapshot of a stemcell written by the inventory
+// errand and compares snapshots of different stemcell builds.
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type Inventory struct {
+	StemcellVersion string          `json:"stemcell_version"`
+	OSVersion       string          `json:"os_version"`
+	Features        []string        `json:"features"`
+	Hotfixes        []Hotfix        `json:"hotfixes"`
+	Services        []Service       `json:"services"`
+	Users           []User          `json:"users"`
+	Certificates    []Certificate   `json:"certificates"`
+	FirewallRules   []FirewallRule  `json:"firewall_rules"`
+	ScheduledTasks  []ScheduledTask `json:"scheduled_tasks"`
+	Programs        []Program       `json:"programs"`
+	BoshBinaries    []File          `json:"bosh_binaries"`
+}
+
+type Hotfix struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+type Service struct {
+	Name      string `json:"name"`
+	StartType string `json:"start_type"`
+	Status    string `json:"status"`
+}
+
+type User struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+type Certificate struct {
+	Store      string `json:"store"`
+	Thumbprint string `json:"thumbprint"`
+	Subject    string `json:"subject"`
+	NotAfter   string `json:"not_after"`
+}
+
+type FirewallRule struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Enabled     string `json:"enabled"`
+	Direction   string `json:"direction"`
+	Action      string `json:"action"`
+}
+
+type ScheduledTask struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+type Program struct {
+	Name string `json:"name"`
+	// Architecture is x64 or x86, the registry view the program is listed in.
+	// The diff keys programs with an empty architecture by Name alone.
+	Architecture string `json:"architecture"`
+	Version      string `json:"version"`
+	Publisher    string `json:"publisher"`
+}
+
+// File is a file in C:\var\vcap\bosh\bin.
+type File struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+}
+
+func Parse(body []byte) (*Inventory, error) {
+	var i Inventory
+	if err := json.Unmarshal(body, &i); err != nil {
+		return nil, fmt.Errorf("unable to parse inventory: %v", err)
+	}
+	return &i, nil
+}
+
+func Load(path string) (*Inventory, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return i, nil
+}
@@ -0,0 +1,158 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const drainWaitSeconds = 10
+
+// hookEvent is a line of the hooks.log written by the lifecycle job.
+type hookEvent struct {
+	Time     time.Time
+	Name     string
+	ExitCode int
+}
+
+func parseHookEvents(log []byte) []hookEvent {
+	var events []hookEvent
+	for _, line := range strings.Split(string(log), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		Expect(fields).To(HaveLen(3), fmt.Sprintf("invalid hooks.log line %q", line))
+
+		ms, err := strconv.ParseInt(fields[0], 10, 64)
+		Expect(err).NotTo(HaveOccurred())
+		exitCode, err := strconv.Atoi(fields[2])
+		Expect(err).NotTo(HaveOccurred())
+
+		events = append(events, hookEvent{Time: time.UnixMilli(ms), Name: fields[1], ExitCode: exitCode})
+	}
+	return events
+}
+
+// expectHookOrder asserts that each of the hooks ran, successfully, in the given order.
+func expectHookOrder(events []hookEvent, names ...string) {
+	last := -1
+	for _, name := range names {
+		index := -1
+		for i := last + 1; i < len(events); i++ {
+			if events[i].Name == name {
+				index = i
+				break
+			}
+		}
+		Expect(index).NotTo(Equal(-1), fmt.Sprintf("expected hooks %v to run in order, got %v", names, hookNames(events)))
+		Expect(events[index].ExitCode).To(BeZero(), fmt.Sprintf("%s exited with %d", name, events[index].ExitCode))
+		last = index
+	}
+}
+
+func hookNames(events []hookEvent) []string {
+	var names []string
+	for _, e := range events {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// expectDrainWaited asserts that the agent honoured the wait asked for by the dynamic drain script.
+func expectDrainWaited(events []hookEvent) {
+	var wait, drain *hookEvent
+	for i := range events {
+		switch events[i].Name {
+		case "drain-wait":
+			wait = &events[i]
+		case "drain":
+			drain = &events[i]
+		}
+	}
+	Expect(wait).NotTo(BeNil(), "the drain script did not ask to wait")
+	Expect(drain).NotTo(BeNil(), "the drain script was not called again after waiting")
+	Expect(drain.Time.Sub(wait.Time)).To(BeNumerically(">=", drainWaitSeconds*time.Second))
+}
+
+var _ = Describe("Job lifecycle", func() {
+	var (
+		lifecycleDeploymentName string
+		seen                    int
+	)
+
+	// newHookEvents returns the events recorded since the previous call.
+	newHookEvents := func() []hookEvent {
+		events := parseHookEvents(downloadLogFile(lifecycleDeploymentName, "lifecycle", 0, "lifecycle/hooks.log", boshCommand))
+		Expect(len(events)).To(BeNumerically(">=", seen), "hooks.log was truncated")
+
+		newEvents := events[seen:]
+		seen = len(events)
+		GinkgoWriter.Printf("Hooks run: %v\n", hookNames(newEvents))
+		return newEvents
+	}
+
+	BeforeEach(func() {
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		lifecycleDeploymentName = fmt.Sprintf("windows-acceptance-test-lifecycle-%d", getTimestampInMs())
+		seen = 0
+
+		err = testConfig.deployWithManifestAndVars(boshCommand, lifecycleDeploymentName, stemcellVersion, releaseVersion,
+			filepath.Join(pwd, "assets", "lifecycle-manifest.yml"),
+			map[string]interface{}{"DrainWaitSeconds": drainWaitSeconds})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if testConfig.SkipCleanup {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", lifecycleDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("runs the lifecycle hooks in order on deploy, stop, start, restart and recreate", func() {
+		By("Deploying")
+		events := newHookEvents()
+		expectHookOrder(events, "pre-start", "run")
+		expectHookOrder(events, "pre-start", "post-start", "post-deploy")
+		Expect(hookNames(events)).NotTo(ContainElement("drain"))
+
+		By("Stopping")
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s stop lifecycle/0", lifecycleDeploymentName))).To(Succeed())
+		events = newHookEvents()
+		Expect(hookNames(events)).To(Equal([]string{"drain-wait", "drain", "stop"}))
+		expectHookOrder(events, "drain-wait", "drain", "stop")
+		expectDrainWaited(events)
+
+		By("Starting")
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s start lifecycle/0", lifecycleDeploymentName))).To(Succeed())
+		events = newHookEvents()
+		expectHookOrder(events, "pre-start", "run")
+		expectHookOrder(events, "pre-start", "post-start")
+		Expect(hookNames(events)).NotTo(ContainElement("drain"))
+
+		By("Restarting")
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s restart lifecycle/0", lifecycleDeploymentName))).To(Succeed())
+		events = newHookEvents()
+		expectHookOrder(events, "drain-wait", "drain", "stop", "pre-start", "post-start")
+		expectDrainWaited(events)
+
+		By("Recreating")
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s recreate lifecycle/0", lifecycleDeploymentName))).To(Succeed())
+		// The log is kept on the persistent disk, so it has the events of the old VM before those of the new one.
+		events = newHookEvents()
+		expectHookOrder(events, "drain-wait", "drain", "stop", "pre-start", "run")
+		expectHookOrder(events, "drain-wait", "drain", "stop", "pre-start", "post-start")
+		expectDrainWaited(events)
+	})
+})
@@ -0,0 +1,187 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+// linkReport is written by the link-consumer errand.
+type linkReport struct {
+	Spec struct {
+		Deployment string `json:"deployment"`
+		Name       string `json:"name"`
+		ID         string `json:"id"`
+		Index      int    `json:"index"`
+		AZ         string `json:"az"`
+		IP         string `json:"ip"`
+		Address    string `json:"address"`
+		Bootstrap  bool   `json:"bootstrap"`
+	} `json:"spec"`
+	Link struct {
+		Address   string         `json:"address"`
+		Greeting  string         `json:"greeting"`
+		Instances []linkInstance `json:"instances"`
+	} `json:"link"`
+	Resolved []struct {
+		Address string   `json:"address"`
+		IPs     []string `json:"ips"`
+		Error   string   `json:"error"`
+	} `json:"resolved"`
+	Env map[string]string `json:"env"`
+}
+
+type linkInstance struct {
+	Name      string `json:"name"`
+	ID        string `json:"id"`
+	Index     int    `json:"index"`
+	AZ        string `json:"az"`
+	Address   string `json:"address"`
+	Bootstrap bool   `json:"bootstrap"`
+}
+
+// boshInstance is a row of `bosh instances --details`.
+type boshInstance struct {
+	Group     string
+	ID        string
+	Index     int
+	AZ        string
+	IP        string
+	Bootstrap bool
+}
+
+func boshInstances(deployment string) map[string]boshInstance {
+	instances := map[string]boshInstance{}
+	for _, row := range boshCommand.RunTable(fmt.Sprintf("-d %s instances --details", deployment)) {
+		group, id, found := strings.Cut(row["instance"], "/")
+		Expect(found).To(BeTrue(), fmt.Sprintf("unexpected instance %q", row["instance"]))
+
+		index, err := strconv.Atoi(row["index"])
+		Expect(err).NotTo(HaveOccurred())
+
+		instances[id] = boshInstance{
+			Group:     group,
+			ID:        id,
+			Index:     index,
+			AZ:        row["az"],
+			IP:        row["ips"],
+			Bootstrap: row["bootstrap"] == "true",
+		}
+	}
+	return instances
+}
+
+var _ = Describe("BOSH links and DNS", Ordered, func() {
+	var (
+		linksDeploymentName string
+		instances           map[string]boshInstance
+		report              linkReport
+	)
+
+	BeforeAll(func() {
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		linksDeploymentName = fmt.Sprintf("windows-acceptance-test-links-%d", getTimestampInMs())
+
+		err = testConfig.deployWithManifest(boshCommand, linksDeploymentName, stemcellVersion, releaseVersion,
+			filepath.Join(pwd, "assets", "links-manifest.yml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		instances = boshInstances(linksDeploymentName)
+
+		body := runErrandAndReadLog(linksDeploymentName, "link-consumer", "link-consumer/links.json", boshCommand)
+		Expect(json.Unmarshal(body, &report)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		if testConfig.SkipCleanup {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", linksDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("renders spec and link values matching the director", func() {
+		By("Rendering spec values")
+		consumer, ok := instances[report.Spec.ID]
+		Expect(ok).To(BeTrue(), fmt.Sprintf("spec.id %s is not an instance of the deployment", report.Spec.ID))
+		Expect(report.Spec.Deployment).To(Equal(linksDeploymentName))
+		Expect(report.Spec.Name).To(Equal("consumer"))
+		Expect(consumer.Group).To(Equal("consumer"))
+		Expect(report.Spec.Index).To(Equal(consumer.Index))
+		Expect(report.Spec.AZ).To(Equal(consumer.AZ))
+		Expect(report.Spec.IP).To(Equal(consumer.IP))
+		Expect(report.Spec.Bootstrap).To(Equal(consumer.Bootstrap))
+		Expect(report.Spec.Address).To(HaveSuffix(".bosh"))
+
+		By("Rendering the provider link")
+		Expect(report.Link.Greeting).To(Equal("hello from link-provider"))
+		Expect(report.Link.Address).To(HaveSuffix(".bosh"))
+		Expect(report.Link.Instances).To(HaveLen(2))
+
+		for _, linked := range report.Link.Instances {
+			provider, ok := instances[linked.ID]
+			Expect(ok).To(BeTrue(), fmt.Sprintf("linked instance %s is not an instance of the deployment", linked.ID))
+			Expect(linked.Name).To(Equal("provider"))
+			Expect(provider.Group).To(Equal("provider"))
+			Expect(linked.Index).To(Equal(provider.Index))
+			Expect(linked.AZ).To(Equal(provider.AZ))
+			Expect(linked.Bootstrap).To(Equal(provider.Bootstrap))
+		}
+	})
+
+	It("resolves link addresses with BOSH DNS", func() {
+		hasDNS, err := harness.HasBoshDNS(boshCommand.Bosh)
+		Expect(err).NotTo(HaveOccurred())
+		if !hasDNS {
+			Skip("Skipping BOSH DNS resolution - no runtime config of the director adds the bosh-dns release")
+		}
+
+		var providerIPs []string
+		addressIPs := map[string]string{}
+		for _, linked := range report.Link.Instances {
+			providerIPs = append(providerIPs, instances[linked.ID].IP)
+			addressIPs[linked.Address] = instances[linked.ID].IP
+		}
+
+		Expect(report.Resolved).To(HaveLen(1 + len(report.Link.Instances)))
+		for _, resolved := range report.Resolved {
+			Expect(resolved.Error).To(BeEmpty(), fmt.Sprintf("unable to resolve %s", resolved.Address))
+			if resolved.Address == report.Link.Address {
+				Expect(resolved.IPs).To(ConsistOf(providerIPs))
+			} else {
+				Expect(resolved.IPs).To(ConsistOf(addressIPs[resolved.Address]))
+			}
+		}
+	})
+
+	It("sets the BOSH_* environment of drain scripts, and none of errands", func() {
+		By("Running the errand")
+		Expect(report.Env).To(BeEmpty(), "the agent sets no BOSH_* environment for errands, their spec values are rendered into templates")
+
+		By("Draining a provider")
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s stop provider/0", linksDeploymentName))).To(Succeed())
+
+		var env map[string]string
+		body := downloadLogFile(linksDeploymentName, "provider", 0, "link-provider/drain-env.json", boshCommand)
+		Expect(json.Unmarshal(body, &env)).To(Succeed())
+		Expect(env).To(HaveKey("BOSH_JOB_STATE"))
+		Expect(env).To(HaveKey("BOSH_JOB_NEXT_STATE"))
+
+		for _, name := range []string{"BOSH_JOB_STATE", "BOSH_JOB_NEXT_STATE"} {
+			var state map[string]interface{}
+			Expect(json.Unmarshal([]byte(env[name]), &state)).To(Succeed(), fmt.Sprintf("%s is not a JSON object: %s", name, env[name]))
+			Expect(state).To(HaveKeyWithValue("persistent_disk", BeNumerically("==", 0)), fmt.Sprintf("%s should describe the persistent disk of the provider, which has none", name))
+		}
+	})
+})
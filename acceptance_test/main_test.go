@@ -1,14 +1,9 @@
 package windows_stemcell_acceptance_test
 
 import (
-	"archive/zip"
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -17,15 +12,13 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
-	"gopkg.in/yaml.v2"
-)
 
-const BoshTimeout = 90 * time.Minute
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/checks"
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/expectations"
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/inventory"
+)
 
-const GoZipFile = "go1.12.7.windows-amd64.zip"
-const GolangURL = "https://storage.googleapis.com/golang/" + GoZipFile
-const LgpoUrl = "https://download.microsoft.com/download/8/5/C/85C25433-A1B0-4FFA-9429-7E023E7DA8D8/LGPO.zip"
-const lgpoFile = "LGPO.exe"
 const redeployRetries = 10
 
 var (
@@ -47,20 +40,19 @@ var _ = BeforeSuite(func() {
 	By(fmt.Sprintf("ReadFile:  '%s'\n%s", configFilePath, string(body)))
 	Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("empty testConfig file path: '%s'", configFilePath))
 
-	err = json.Unmarshal(body, &testConfig)
-	Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("unable to parse testConfig file '%s', %v:", string(body), err))
-
-	Expect(testConfig.StemcellOs).ToNot(BeEmpty(), fmt.Sprintf("missing required field: '%s'", "stemcell_os"))
+	pwd, err := os.Getwd()
+	Expect(err).NotTo(HaveOccurred())
 
-	if testConfig.VmExtensions == "" {
-		testConfig.VmExtensions = "500GB_ephemeral_disk"
-	}
+	config, err := harness.ParseConfig(body, pwd)
+	Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("invalid testConfig file '%s'", configFilePath))
+	testConfig = &TestConfig{Config: *config}
+	Expect(testConfig.Validate()).To(Succeed(), fmt.Sprintf("invalid testConfig file '%s'", configFilePath))
+	testConfig.expectations()
 
 	boshCommand = newBoshCommand(testConfig)
 
 	err = boshCommand.Run("login")
 	Expect(err).NotTo(HaveOccurred())
-	deploymentName = fmt.Sprintf("windows-acceptance-test-%d", getTimestampInMs())
 
 	stemcellYML, err := fetchStemcellInfo(testConfig.StemcellPath)
 	Expect(err).NotTo(HaveOccurred())
@@ -68,15 +60,53 @@ var _ = BeforeSuite(func() {
 	stemcellName = stemcellYML.Name
 	stemcellVersion = stemcellYML.Version
 
-	releaseVersion = createBwatsRelease(boshCommand)
+	if testConfig.ExistingDeployment != "" {
+		// The stemcell and release are already uploaded, as the deployment uses them.
+		deploymentName = testConfig.ExistingDeployment
+		releaseVersion, err = testConfig.VerifyExistingDeployment(boshCommand.Bosh, pwd, stemcellYML)
+		Expect(err).NotTo(HaveOccurred())
+		skipDryRun()
+		return
+	}
+	deploymentName = fmt.Sprintf("windows-acceptance-test-%d", getTimestampInMs())
+
+	if testConfig.ExistingReleaseVersion != "" {
+		exists, err := harness.ReleaseExists(boshCommand.Bosh, testConfig.ExistingReleaseVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exists).To(BeTrue(), fmt.Sprintf("existing_release_version %s has not been uploaded", testConfig.ExistingReleaseVersion))
+		releaseVersion = testConfig.ExistingReleaseVersion
+	} else {
+		releaseVersion = createBwatsRelease(boshCommand)
+	}
 
 	uploadStemcell(testConfig, boshCommand)
 
 	err = testConfig.deploy(boshCommand, deploymentName, stemcellVersion, releaseVersion)
 	Expect(err).NotTo(HaveOccurred())
+	skipDryRun()
 })
 
+// skipDryRun skips the specs of a dry run, whose deployment only exists in the
+// plan.
+func skipDryRun() {
+	if boshCommand.Plan != nil {
+		Skip(fmt.Sprintf("Dry run - skipping the specs, the plan is written to %s", harness.DryRunDir()))
+	}
+}
+
 var _ = AfterSuite(func() {
+	if boshCommand.Plan != nil {
+		defer func() {
+			Expect(boshCommand.Plan.Write(harness.DryRunDir())).To(Succeed())
+		}()
+	}
+
+	// Exported once the specs ran, so that the packages they deploy, such as
+	// slow-compile, are compiled by the specs rather than by the export.
+	if testConfig.ExistingDeployment == "" && releaseVersion != "" {
+		exportCompiledRelease(boshCommand, deploymentName, releaseVersion)
+	}
+
 	// Delete the releases created by the tight loop test
 	for index, version := range tightLoopStemcellVersions {
 		if index == len(tightLoopStemcellVersions)-1 {
@@ -89,20 +119,23 @@ var _ = AfterSuite(func() {
 		return
 	}
 
-	err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", deploymentName))
-	Expect(err).NotTo(HaveOccurred())
-	err = boshCommand.Run(fmt.Sprintf("delete-stemcell %s/%s", stemcellName, stemcellVersion))
-	Expect(err).NotTo(HaveOccurred())
-	err = boshCommand.Run(fmt.Sprintf("delete-release bwats-release/%s", releaseVersion))
-	Expect(err).NotTo(HaveOccurred())
+	// What the suite did not create is left in place.
+	if testConfig.ExistingDeployment == "" {
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", deploymentName))
+		Expect(err).NotTo(HaveOccurred())
+		err = boshCommand.Run(fmt.Sprintf("delete-stemcell %s/%s", stemcellName, stemcellVersion))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	if testConfig.ExistingDeployment == "" && testConfig.ExistingReleaseVersion == "" {
+		err := boshCommand.Run(fmt.Sprintf("delete-release bwats-release/%s", releaseVersion))
+		Expect(err).NotTo(HaveOccurred())
+	}
 	if len(tightLoopStemcellVersions) != 0 {
-		err = boshCommand.Run(fmt.Sprintf("delete-release bwats-release/%s", tightLoopStemcellVersions[len(tightLoopStemcellVersions)-1]))
+		err := boshCommand.Run(fmt.Sprintf("delete-release bwats-release/%s", tightLoopStemcellVersions[len(tightLoopStemcellVersions)-1]))
 		Expect(err).NotTo(HaveOccurred())
 	}
 
-	if boshCommand.CertPath != "" {
-		Expect(os.RemoveAll(boshCommand.CertPath)).To(Succeed())
-	}
+	Expect(boshCommand.Cleanup()).To(Succeed())
 })
 
 var _ = Describe("BOSH Windows", func() {
@@ -113,9 +146,15 @@ var _ = Describe("BOSH Windows", func() {
 	})
 
 	It("successfully runs redeploy in a tight loop", func() {
+		if testConfig.ExistingDeployment != "" {
+			Skip("Skipping tight loop test - it would leave existing_deployment on a release it created")
+		}
+
 		pwd, err := os.Getwd()
 		Expect(err).To(BeNil())
-		releaseDir := filepath.Join(pwd, "assets", "bwats-release")
+		releaseDir, err := harness.PrepareRelease(boshCommand.Bosh, pwd, &testConfig.Config)
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(releaseDir) //nolint:errcheck
 
 		f, err := os.OpenFile(filepath.Join(releaseDir, "jobs", "simple-job", "templates", "pre-start.ps1"),
 			os.O_APPEND|os.O_WRONLY, 0600)
@@ -127,6 +166,8 @@ var _ = Describe("BOSH Windows", func() {
 
 			version := fmt.Sprintf("0.dev+%d", getTimestampInMs())
 			tightLoopStemcellVersions = append(tightLoopStemcellVersions, version)
+			_, err = fmt.Fprintf(f, "\n# redeploy attempt %d\n", i)
+			Expect(err).ToNot(HaveOccurred())
 			Expect(boshCommand.RunIn(fmt.Sprintf("create-release --force --version %s", version), releaseDir)).To(Succeed())
 
 			Expect(boshCommand.RunIn("upload-release", releaseDir)).To(Succeed())
@@ -141,22 +182,35 @@ var _ = Describe("BOSH Windows", func() {
 	})
 
 	It("checks system dependencies and security, auto update has turned off, currently has a Service StartType of 'Manual' and initially had a StartType of 'Delayed', and password is randomized", func() {
-		err := boshCommand.RunErrand("check-system", deploymentName)
-		Expect(err).NotTo(HaveOccurred())
-	})
+		stdout, err := boshCommand.RunErrandStdOut("check-system", deploymentName)
 
-	It("is fully updated", func() { // 860s
-		if testConfig.SkipMSUpdateTest {
-			Skip("Skipping check-updates test - SkipMSUpdateTest set to true")
-		} else {
-			err := boshCommand.RunErrand("check-updates", deploymentName)
-			Expect(err).NotTo(HaveOccurred())
+		report := checks.ParseReport(stdout)
+		AddReportEntry("check-system", report.String())
+		if skipped := report.WithStatus(checks.Skipped); len(skipped) != 0 {
+			GinkgoWriter.Printf("Skipped checks: %s\n", strings.Join(skipped, ", "))
 		}
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.WithStatus(checks.Failed)).To(BeEmpty())
 	})
 
-	It("has all certificate authority certs that are present on the Windows Update Server", func() {
-		err := boshCommand.RunErrand("check-wu-certs", deploymentName)
+	It("records an inventory of the stemcell", func() {
+		body := runErrandAndReadLog(deploymentName, "inventory", "inventory/inventory.json", boshCommand)
+
+		current, err := inventory.Parse(body)
 		Expect(err).NotTo(HaveOccurred())
+		Expect(current.Services).NotTo(BeEmpty())
+		Expect(current.BoshBinaries).To(ContainElement(HaveField("Name", "job-service-wrapper.exe")))
+
+		if testConfig.InventoryPath != "" {
+			Expect(os.WriteFile(testConfig.InventoryPath, body, 0644)).To(Succeed())
+		}
+
+		if testConfig.BaselineInventoryPath != "" {
+			baseline, err := inventory.Load(testConfig.BaselineInventoryPath)
+			Expect(err).NotTo(HaveOccurred())
+			AddReportEntry("inventory changes", inventory.Diff(baseline, current).String())
+		}
 	})
 
 	It("mounts ephemeral disks when asked to do so and does not mount them otherwise", func() {
@@ -172,7 +226,7 @@ var _ = Describe("BOSH Windows", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("deploys when there is a slow to compile go package", func() {
+		It("deploys and runs a slow to compile go package", func() {
 			pwd, err := os.Getwd()
 			Expect(err).NotTo(HaveOccurred())
 			manifestPath = filepath.Join(pwd, "assets", "slow-compile-manifest.yml")
@@ -181,420 +235,237 @@ var _ = Describe("BOSH Windows", func() {
 
 			err = testConfig.deployWithManifest(boshCommand, slowCompilingDeploymentName, stemcellVersion, releaseVersion, manifestPath)
 			Expect(err).NotTo(HaveOccurred())
-		})
-	})
 
-	Context("ssh enabled", func() {
-		It("allows SSH connection", func() {
-			err := boshCommand.Run(fmt.Sprintf("-d %s ssh --opts=-T --command=exit", deploymentName))
+			compileTime, compiled, err := harness.PackageCompileTime(boshCommand.Bosh, slowCompilingDeploymentName, "slow-compile")
 			Expect(err).NotTo(HaveOccurred())
+			if compiled {
+				AddReportEntry("slow-compile compile time", fmt.Sprintf("%s, targeting %s for %d files with a fan-out of %d",
+					compileTime, testConfig.SlowCompile.TargetDuration, testConfig.SlowCompile.Files, testConfig.SlowCompile.FanOut))
+			} else {
+				GinkgoWriter.Printf("slow-compile was already compiled for stemcell %s\n", stemcellVersion)
+			}
+
+			Eventually(func() string {
+				return string(downloadLogFile(slowCompilingDeploymentName, "slow-compile", 0, "slow-compile/slow-compile/job-service-wrapper.out.log", boshCommand))
+			}, 3*time.Minute, 20*time.Second).Should(ContainSubstring(fmt.Sprintf("slow-compile ran %d packages", testConfig.SlowCompile.Files)))
 		})
+	})
 
-		It("cleans up ssh users after a successful connection", func() {
-			err := boshCommand.Run(fmt.Sprintf("-d %s ssh --opts=-T --command=exit", deploymentName))
-			Expect(err).NotTo(HaveOccurred())
+	It("compiles the packages of the source release unless it was uploaded from the compiled release cache", func() {
+		if testConfig.ExistingDeployment != "" || testConfig.ExistingReleaseVersion != "" {
+			Skip("Skipping source compilation test - the release was not created by this run")
+		}
 
-			err = boshCommand.RunErrand("check-ssh", deploymentName) // test for C:\Users only having one ssh user, net users only containing one ssh user.
+		// The cache is only written after the specs, so it has the release
+		// only if the release was uploaded from it.
+		cache, err := testConfig.ReleaseCache()
+		Expect(err).NotTo(HaveOccurred())
+		if cache != nil {
+			contentHash, err := harness.UploadedReleaseContentHash(boshCommand.Bosh, releaseVersion)
 			Expect(err).NotTo(HaveOccurred())
-		})
+			if compiled, cached := cache.Lookup(contentHash); cached {
+				Skip(fmt.Sprintf("Skipping source compilation test - release %s was uploaded compiled from %s", releaseVersion, compiled))
+			}
+		}
+
+		packages, err := harness.ReleasePackages(boshCommand.Bosh, releaseVersion)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(packages).NotTo(BeEmpty())
+		for name, compiledFor := range packages {
+			Expect(compiledFor).To(ContainElement("(source)"), fmt.Sprintf("package %s was not uploaded as source", name))
+		}
+		// The jobs of the deployment depend on golang-windows.
+		Expect(packages["golang-windows"]).To(ContainElement(fmt.Sprintf("%s/%s", testConfig.StemcellOs, stemcellVersion)))
 	})
 })
 
+// TestConfig is the harness config, with helpers that fail the spec on error.
 type TestConfig struct {
-	Bosh struct {
-		CaCert       string `json:"ca_cert"`
-		Client       string `json:"client"`
-		ClientSecret string `json:"client_secret"`
-		Target       string `json:"target"`
-	} `json:"bosh"`
-	StemcellPath              string `json:"stemcell_path"`
-	StemcellOs                string `json:"stemcell_os"`
-	Az                        string `json:"az"`
-	VmType                    string `json:"vm_type"`
-	RootEphemeralVmType       string `json:"root_ephemeral_vm_type"`
-	VmExtensions              string `json:"vm_extensions"`
-	Network                   string `json:"network"`
-	DefaultUsername           string `json:"default_username"`
-	DefaultPassword           string `json:"default_password"`
-	SkipCleanup               bool   `json:"skip_cleanup"`
-	MountEphemeralDisk        bool   `json:"mount_ephemeral_disk"`
-	SkipMSUpdateTest          bool   `json:"skip_ms_update_test"`
-	SSHDisabledByDefault      bool   `json:"ssh_disabled_by_default"`
-	SecurityComplianceApplied bool   `json:"security_compliance_applied"`
-}
-
-func getTimestampInMs() int64 {
-	return time.Now().UTC().UnixNano() / int64(time.Millisecond)
-}
-
-type StemcellYML struct {
-	Version string `yaml:"version"`
-	Name    string `yaml:"name"`
+	harness.Config
 }
 
-func fetchStemcellInfo(stemcellPath string) (StemcellYML, error) {
-	var stemcellInfo StemcellYML
-	tempDir, err := os.MkdirTemp("", "")
-	Expect(err).NotTo(HaveOccurred())
-	defer os.RemoveAll(tempDir) //nolint:errcheck
-
-	cmd := exec.Command("tar", "xf", stemcellPath, "-C", tempDir, "stemcell.MF")
-	session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+func (c *TestConfig) expectations() expectations.Expectations {
+	e, err := c.Expectations()
 	Expect(err).NotTo(HaveOccurred())
-	Eventually(session, 20*time.Minute).Should(gexec.Exit())
 
-	exitCode := session.ExitCode()
-	if exitCode != 0 {
-		var stderr []byte
-		if session.Err != nil {
-			stderr = session.Err.Contents()
-		}
-		stdout := session.Out.Contents()
-		return stemcellInfo, fmt.Errorf("Non-zero exit code for cmd %q: %d\nSTDERR:\n%s\nSTDOUT:%s\n",
-			strings.Join(cmd.Args, " "), exitCode, stderr, stdout)
-	}
-
-	stemcellMF, err := os.ReadFile(fmt.Sprintf("%s/%s", tempDir, "stemcell.MF"))
-	Expect(err).NotTo(HaveOccurred())
+	return e
+}
 
-	err = yaml.Unmarshal(stemcellMF, &stemcellInfo)
-	Expect(err).NotTo(HaveOccurred())
-	Expect(stemcellInfo.Version).ToNot(BeNil())
-	Expect(stemcellInfo.Version).ToNot(BeEmpty())
+func getTimestampInMs() int64 {
+	return harness.TimestampInMs()
+}
 
-	return stemcellInfo, nil
+func fetchStemcellInfo(stemcellPath string) (harness.StemcellYML, error) {
+	return harness.FetchStemcellInfo(stemcellPath)
 }
 
+// BoshCommand is the harness bosh runner, with helpers that fail the spec on
+// error.
 type BoshCommand struct {
-	DirectorIP   string
-	Client       string
-	ClientSecret string
-	CertPath     string // Path to CA CERT file, if any
-	Timeout      time.Duration
+	*harness.Bosh
 }
 
 func newBoshCommand(config *TestConfig) *BoshCommand {
-	var boshCertPath string
-	cert := config.Bosh.CaCert
-	if cert != "" {
-		certFile, err := os.CreateTemp("", "")
-		Expect(err).NotTo(HaveOccurred())
-
-		_, err = certFile.Write([]byte(cert))
-		Expect(err).NotTo(HaveOccurred())
-
-		boshCertPath, err = filepath.Abs(certFile.Name())
-		Expect(err).NotTo(HaveOccurred())
-	}
-
-	timeout := BoshTimeout
-	var err error
-	if s := os.Getenv("BWATS_BOSH_TIMEOUT"); s != "" {
-		timeout, err = time.ParseDuration(s)
-		GinkgoWriter.Printf("Using BWATS_BOSH_TIMEOUT (%s) as timeout\n", s)
-
-		if err != nil {
-			GinkgoWriter.Printf("Error parsing BWATS_BOSH_TIMEOUT (%s): %s - falling back to default\n", s, err)
-		}
-	}
-
-	return &BoshCommand{
-		DirectorIP:   config.Bosh.Target,
-		Client:       config.Bosh.Client,
-		ClientSecret: config.Bosh.ClientSecret,
-		CertPath:     boshCertPath,
-		Timeout:      timeout,
-	}
-}
+	bosh, err := harness.NewBosh(&config.Config, GinkgoWriter)
+	Expect(err).NotTo(HaveOccurred())
 
-func (c *BoshCommand) args(command string) []string {
-	args := strings.Split(command, " ")
-	args = append([]string{"-n", "-e", c.DirectorIP, "--client", c.Client, "--client-secret", c.ClientSecret}, args...)
-	if c.CertPath != "" {
-		args = append([]string{"--ca-cert", c.CertPath}, args...)
-	}
-	return args
+	return &BoshCommand{Bosh: bosh}
 }
 
-func (c *BoshCommand) Run(command string) error {
-	return c.RunIn(command, "")
-}
+// RunTable runs a command with --json and returns the rows of the first table
+// it prints, e.g. for `vms` or `instances`.
+func (c *BoshCommand) RunTable(command string) []map[string]string {
+	rows, err := c.Bosh.RunTable(command)
+	Expect(err).NotTo(HaveOccurred())
 
-func (c *BoshCommand) RunErrand(errandName string, deploymentName string) error {
-	return c.Run(fmt.Sprintf("-d %s run-errand --download-logs %s --tty", deploymentName, errandName))
+	return rows
 }
 
-func (c *BoshCommand) RunInStdOut(command, dir string) ([]byte, error) {
-	cmd := exec.Command("bosh", c.args(command)...)
-
-	if dir != "" {
-		cmd.Dir = dir
-		GinkgoWriter.Printf("\nRUNNING %q IN %q\n", strings.Join(cmd.Args, " "), dir)
-	} else {
-		GinkgoWriter.Printf("\nRUNNING %q\n", strings.Join(cmd.Args, " "))
+// Start runs a command that does not exit on its own, e.g. `bosh ssh` with port
+// forwarding. The caller terminates the session. Such commands are not
+// recorded, and the spec is skipped when replaying.
+func (c *BoshCommand) Start(command string) *gexec.Session {
+	cmd, err := c.Command(command)
+	if err != nil && c.Replayer != nil {
+		Skip(err.Error())
 	}
+	Expect(err).NotTo(HaveOccurred())
+	Expect(cmd).NotTo(BeNil(), "%q was planned rather than started", command)
 
 	session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
-	if err != nil {
-		return nil, err
-	}
-	Eventually(session, c.Timeout).Should(gexec.Exit())
-
-	exitCode := session.ExitCode()
-	stdout := session.Out.Contents()
-	if exitCode != 0 {
-		var stderr []byte
-		if session.Err != nil {
-			stderr = session.Err.Contents()
-		}
-		return stdout,
-			fmt.Errorf(
-				"Non-zero exit code for cmd %q: %d\nSTDERR:\n%s\nSTDOUT:%s\n",
-				strings.Join(cmd.Args, " "), exitCode, stderr, stdout,
-			)
-	}
-	return stdout, nil
+	Expect(err).NotTo(HaveOccurred())
+	return session
 }
 
-func (c *BoshCommand) RunIn(command, dir string) error {
-	_, err := c.RunInStdOut(command, dir)
-	return err
+// stopSession interrupts a session run with Start and waits for it to exit.
+// Killing `bosh ssh` instead would leave its user on the instance, as it
+// removes the user when interrupted.
+func stopSession(session *gexec.Session) {
+	session.Interrupt()
+	Eventually(session, 2*time.Minute).Should(gexec.Exit())
 }
 
 func uploadStemcell(config *TestConfig, bosh *BoshCommand) {
-	matches, err := filepath.Glob(config.StemcellPath)
-	Expect(err).NotTo(HaveOccurred())
-	Expect(matches).To(HaveLen(1))
-
-	for {
-		// the ami may not be immediately available, so we retry every three minutes.
-		// if it is actually broken, the concourse timeout will kick in at 90 minutes.
-		err = bosh.Run(fmt.Sprintf("upload-stemcell %s", matches[0]))
-		if err != nil {
-			time.Sleep(3 * time.Minute)
-		} else {
-			break
-		}
-	}
+	uploadStemcellFile(config.StemcellPath, bosh)
+}
 
-	Expect(err).NotTo(HaveOccurred())
+func uploadStemcellFile(stemcellPath string, bosh *BoshCommand) {
+	Expect(harness.UploadStemcell(bosh.Bosh, stemcellPath)).To(Succeed())
 }
 
 func createBwatsRelease(bosh *BoshCommand) string {
 	pwd, err := os.Getwd()
 	Expect(err).NotTo(HaveOccurred())
 
-	releaseVersion = fmt.Sprintf("0.dev+%d", getTimestampInMs())
-	var goZipPath string
-	if _, err = os.Stat(filepath.Join(pwd, GoZipFile)); os.IsNotExist(err) {
-		goZipPath, err = downloadFile("golang-", GolangURL)
-		Expect(err).NotTo(HaveOccurred())
-	} else {
-		goZipPath = filepath.Join(pwd, GoZipFile)
-	}
-	releaseDir := filepath.Join(pwd, "assets", "bwats-release")
-	Expect(bosh.RunIn(fmt.Sprintf("add-blob %s golang-windows/%s", goZipPath, GoZipFile), releaseDir)).To(Succeed())
-
-	var lgpoZipPath string
-	if _, err = os.Stat(filepath.Join(pwd, "LGPO.zip")); os.IsNotExist(err) {
-		lgpoZipPath, err = downloadFile("lgpo-", LgpoUrl)
-		Expect(err).NotTo(HaveOccurred())
-	} else {
-		lgpoZipPath = filepath.Join(pwd, "LGPO.zip")
-	}
-
-	zipReader, err := zip.OpenReader(lgpoZipPath)
-	Expect(err).NotTo(HaveOccurred())
-
-	lgpoPath, err := os.CreateTemp("", lgpoFile)
+	releaseVersion, err = harness.CreateRelease(bosh.Bosh, pwd, &testConfig.Config)
 	Expect(err).NotTo(HaveOccurred())
 
-	for _, zipFile := range zipReader.File {
-		if zipFile.Name == fmt.Sprintf("LGPO_30/%s", lgpoFile) {
-			var f *os.File
-			f, err = os.OpenFile(lgpoPath.Name(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, zipFile.Mode())
-			Expect(err).NotTo(HaveOccurred())
-
-			var zipRC io.ReadCloser
-			zipRC, err = zipFile.Open()
-			Expect(err).NotTo(HaveOccurred())
-
-			_, err = io.Copy(f, zipRC)
-			Expect(err).NotTo(HaveOccurred())
-
-			err = f.Close()
-			Expect(err).NotTo(HaveOccurred())
-
-			err = zipRC.Close()
-			Expect(err).NotTo(HaveOccurred())
-		}
-	}
-
-	Expect(lgpoPath.Name()).To(BeAnExistingFile())
-	Expect(bosh.RunIn(fmt.Sprintf("add-blob %s lgpo/%s", lgpoPath.Name(), lgpoFile), releaseDir)).To(Succeed())
-
-	Expect(bosh.RunIn(fmt.Sprintf("create-release --force --version %s", releaseVersion), releaseDir)).To(Succeed())
-	Expect(bosh.RunIn("upload-release", releaseDir)).To(Succeed())
-
 	return releaseVersion
 }
 
-type ManifestProperties struct {
-	DeploymentName            string
-	ReleaseName               string
-	AZ                        string
-	VmType                    string
-	RootEphemeralVmType       string
-	VmExtensions              string
-	Network                   string
-	StemcellOs                string
-	StemcellVersion           string
-	ReleaseVersion            string
-	DefaultUsername           string
-	DefaultPassword           string
-	MountEphemeralDisk        bool
-	SSHDisabledByDefault      bool
-	SecurityComplianceApplied bool
+// exportCompiledRelease caches the release compiled by a deployment, when the
+// config has a compiled_release_cache.
+func exportCompiledRelease(bosh *BoshCommand, deployment string, version string) {
+	cache, err := testConfig.ReleaseCache()
+	Expect(err).NotTo(HaveOccurred())
+	if cache != nil {
+		Expect(cache.Export(bosh.Bosh, deployment, version)).To(Succeed())
+	}
 }
 
-func (m ManifestProperties) toVarsString() string {
-	manifest := m.toMap()
-
-	fmtString := "-v %s=%s "
+func downloadLogs(instanceName string, jobName string, index int, bosh *BoshCommand) *gbytes.Buffer {
+	buffer := gbytes.NewBuffer()
+	_, err := buffer.Write(downloadLogFile(deploymentName, instanceName, index, fmt.Sprintf("%s/%s/job-service-wrapper.out.log", jobName, jobName), bosh))
+	Expect(err).NotTo(HaveOccurred())
 
-	var b bytes.Buffer
+	return buffer
+}
 
-	for k, v := range manifest {
-		if v != "" {
-			_, err := fmt.Fprintf(&b, fmtString, k, v)
-			Expect(err).NotTo(HaveOccurred())
-		}
-	}
+// downloadLogFile downloads the logs of an instance and returns the contents of
+// one of them, e.g. "lifecycle/hooks.log".
+func downloadLogFile(deployment string, instanceName string, index int, logFile string, bosh *BoshCommand) []byte {
+	tempDir, err := os.MkdirTemp("", "")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(tempDir) //nolint:errcheck
 
-	boolOperators := []string{
-		fmt.Sprintf("-v MountEphemeralDisk=%t", m.MountEphemeralDisk),
-		fmt.Sprintf("-v SSHDisabledByDefault=%t", m.SSHDisabledByDefault),
-		fmt.Sprintf("-v SecurityComplianceApplied=%t", m.SecurityComplianceApplied),
-	}
+	return readFromTarball(downloadLogsTarball(deployment, instanceName, index, tempDir, bosh), logFile)
+}
 
-	_, err := fmt.Fprint(&b, strings.Join(boolOperators, " "))
+// downloadLogsTarball downloads the logs of an instance into dir and returns
+// the path of the tarball, for reading several log files from one download.
+func downloadLogsTarball(deployment string, instanceName string, index int, dir string, bosh *BoshCommand) string {
+	tarball, err := harness.DownloadLogsTarball(bosh.Bosh, deployment, instanceName, index, dir)
 	Expect(err).NotTo(HaveOccurred())
 
-	return b.String()
+	return tarball
 }
 
-func (m ManifestProperties) toMap() map[string]string {
-	manifest := make(map[string]string)
-
-	manifest["DeploymentName"] = m.DeploymentName
-	manifest["ReleaseName"] = m.ReleaseName
-	manifest["AZ"] = m.AZ
-	manifest["VmType"] = m.VmType
-	manifest["RootEphemeralVmType"] = m.RootEphemeralVmType
-	manifest["VmExtensions"] = m.VmExtensions
-	manifest["Network"] = m.Network
-	manifest["StemcellOs"] = m.StemcellOs
-	manifest["StemcellVersion"] = m.StemcellVersion
-	manifest["ReleaseVersion"] = m.ReleaseVersion
-	manifest["DefaultUsername"] = m.DefaultUsername
-	manifest["DefaultPassword"] = m.DefaultPassword
-
-	return manifest
+// runErrandAndReadLog runs an errand and returns the contents of a file it
+// wrote to its log directory, e.g. "inventory/inventory.json".
+func runErrandAndReadLog(deployment string, errandName string, logFile string, bosh *BoshCommand) []byte {
+	logs := runErrandAndReadLogs(deployment, errandName, logFile, bosh)
+	Expect(logs).To(HaveLen(1))
+
+	return logs[0]
 }
 
-func downloadLogs(instanceName string, jobName string, index int, bosh *BoshCommand) *gbytes.Buffer {
+// runErrandAndReadLogs is runErrandAndReadLog for errands that run on several
+// instances. It returns the file written by each of them.
+func runErrandAndReadLogs(deployment string, errandName string, logFile string, bosh *BoshCommand) [][]byte {
 	tempDir, err := os.MkdirTemp("", "")
 	Expect(err).NotTo(HaveOccurred())
 	defer os.RemoveAll(tempDir) //nolint:errcheck
 
-	err = bosh.Run(fmt.Sprintf("-d %s logs %s/%d --dir %s", deploymentName, instanceName, index, tempDir))
+	tarballs, err := harness.RunErrandAndDownloadLogs(bosh.Bosh, deployment, errandName, tempDir)
 	Expect(err).NotTo(HaveOccurred())
 
-	matches, err := filepath.Glob(filepath.Join(tempDir, fmt.Sprintf("%s.%s.%d-*.tgz", deploymentName, instanceName, index)))
-	Expect(err).NotTo(HaveOccurred())
-	Expect(matches).To(HaveLen(1))
+	var logs [][]byte
+	for _, tarball := range tarballs {
+		logs = append(logs, readFromTarball(tarball, logFile))
+	}
+	return logs
+}
 
-	cmd := exec.Command("tar", "xf", matches[0], "-O", fmt.Sprintf("./%s/%s/job-service-wrapper.out.log", jobName, jobName))
-	session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+// runErrandAndReadReport runs an errand that writes a JSON report to logFile
+// before failing on what it reports, and decodes the report into report. The
+// error of the errand is returned rather than asserted, so that the caller can
+// first assert the report, which explains the failure.
+func runErrandAndReadReport(deployment string, errandName string, logFile string, report interface{}, bosh *BoshCommand) error {
+	tempDir, err := os.MkdirTemp("", "")
 	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(tempDir) //nolint:errcheck
 
-	return session.Wait().Out
-}
+	tarballs, errandErr := harness.RunErrandAndDownloadLogs(bosh.Bosh, deployment, errandName, tempDir)
+	Expect(tarballs).To(HaveLen(1), fmt.Sprintf("%s did not download its logs: %v", errandName, errandErr))
 
-func downloadFile(prefix, sourceUrl string) (string, error) {
-	tempFile, err := os.CreateTemp("", prefix)
-	if err != nil {
-		return "", err
-	}
+	body, err := harness.ReadFromTarball(tarballs[0], logFile)
+	Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("%s did not write %s: %v", errandName, logFile, errandErr))
+	Expect(json.Unmarshal(body, report)).To(Succeed(), fmt.Sprintf("%s is not valid JSON", logFile))
 
-	filename := tempFile.Name()
-	f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close() //nolint:errcheck
+	return errandErr
+}
 
-	res, err := http.Get(sourceUrl)
-	if err != nil {
-		return "", err
-	}
-	defer res.Body.Close() //nolint:errcheck
-	if _, err := io.Copy(f, res.Body); err != nil {
-		return "", err
-	}
+func readFromTarball(tarball string, path string) []byte {
+	body, err := harness.ReadFromTarball(tarball, path)
+	Expect(err).NotTo(HaveOccurred())
 
-	return filename, nil
+	return body
 }
 
 func (c *TestConfig) deployWithManifest(bosh *BoshCommand, deploymentName string, stemcellVersion string, bwatsVersion string, manifestPath string) error {
-	manifestProperties := ManifestProperties{
-		DeploymentName:            deploymentName,
-		ReleaseName:               "bwats-release",
-		AZ:                        c.Az,
-		VmType:                    c.VmType,
-		RootEphemeralVmType:       c.RootEphemeralVmType,
-		VmExtensions:              c.VmExtensions,
-		Network:                   c.Network,
-		DefaultUsername:           c.DefaultUsername,
-		DefaultPassword:           c.DefaultPassword,
-		StemcellOs:                c.StemcellOs,
-		StemcellVersion:           fmt.Sprintf(`"%s"`, stemcellVersion),
-		ReleaseVersion:            bwatsVersion,
-		MountEphemeralDisk:        c.MountEphemeralDisk,
-		SSHDisabledByDefault:      c.SSHDisabledByDefault,
-		SecurityComplianceApplied: c.SecurityComplianceApplied,
-	}
-
-	var err error
-
-	if c.RootEphemeralVmType != "" {
-		var pwd string
-		pwd, err = os.Getwd()
-		Expect(err).NotTo(HaveOccurred())
-		opsFilePath := filepath.Join(pwd, "assets", "root-disk-as-ephemeral.yml")
-
-		err = bosh.Run(fmt.Sprintf(
-			"-d %s deploy %s -o %s %s",
-			deploymentName,
-			manifestPath,
-			opsFilePath,
-			manifestProperties.toVarsString(),
-		))
-	} else {
-		err = bosh.Run(fmt.Sprintf("-d %s deploy %s %s", deploymentName, manifestPath, manifestProperties.toVarsString()))
-	}
-
-	if err != nil {
-		return err
-	}
+	return c.deployWithManifestAndVars(bosh, deploymentName, stemcellVersion, bwatsVersion, manifestPath, nil)
+}
 
-	return nil
+// deployWithManifestAndVars deploys a manifest with the variables set from the
+// test config, plus any variables and ops files specific to that manifest.
+func (c *TestConfig) deployWithManifestAndVars(bosh *BoshCommand, deploymentName string, stemcellVersion string, bwatsVersion string, manifestPath string, vars map[string]interface{}, opsFilePaths ...string) error {
+	return c.DeployWithManifestAndVars(bosh.Bosh, deploymentName, stemcellVersion, bwatsVersion, manifestPath, vars, opsFilePaths...)
 }
 
 func (c *TestConfig) deploy(bosh *BoshCommand, deploymentName string, stemcellVersion string, bwatsVersion string) error {
 	pwd, err := os.Getwd()
 	Expect(err).NotTo(HaveOccurred())
-	manifestPath = filepath.Join(pwd, "assets", "manifest.yml")
+	manifestPath = harness.MainManifestPath(pwd)
 
-	return c.deployWithManifest(bosh, deploymentName, stemcellVersion, bwatsVersion, manifestPath)
+	return c.Deploy(bosh.Bosh, pwd, deploymentName, stemcellVersion, bwatsVersion)
 }
@@ -0,0 +1,172 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// networkReport is written by the check-network errand.
+type networkReport struct {
+	Networks map[string]struct {
+		IP      string   `json:"ip"`
+		Default []string `json:"default"`
+	} `json:"networks"`
+	Interfaces []struct {
+		Alias      string   `json:"alias"`
+		Index      int      `json:"index"`
+		IPs        []string `json:"ips"`
+		DNSServers []string `json:"dns_servers"`
+	} `json:"interfaces"`
+	DefaultRoutes []struct {
+		NextHop        string `json:"next_hop"`
+		InterfaceIndex int    `json:"interface_index"`
+		Metric         int    `json:"metric"`
+	} `json:"default_routes"`
+	MetadataServerRules []struct {
+		Name      string `json:"name"`
+		Enabled   bool   `json:"enabled"`
+		Direction string `json:"direction"`
+		Action    string `json:"action"`
+	} `json:"metadata_server_rules"`
+}
+
+// interfaceWithIP returns the index of the interface the IP is assigned to.
+func (r networkReport) interfaceWithIP(ip string) int {
+	for _, i := range r.Interfaces {
+		for _, address := range i.IPs {
+			if address == ip {
+				return i.Index
+			}
+		}
+	}
+	Fail(fmt.Sprintf("no interface has the IP %s", ip))
+	return 0
+}
+
+func (r networkReport) dnsServers(interfaceIndex int) []string {
+	for _, i := range r.Interfaces {
+		if i.Index == interfaceIndex {
+			return i.DNSServers
+		}
+	}
+	return nil
+}
+
+// defaultInterface is the interface of the default route with the lowest metric.
+func (r networkReport) defaultInterface() int {
+	Expect(r.DefaultRoutes).NotTo(BeEmpty(), "there is no default route")
+
+	best := r.DefaultRoutes[0]
+	for _, route := range r.DefaultRoutes[1:] {
+		if route.Metric < best.Metric {
+			best = route
+		}
+	}
+	return best.InterfaceIndex
+}
+
+var _ = Describe("Networks", func() {
+	var networksDeploymentName string
+
+	deployNetworks := func(networks []map[string]interface{}) (networkReport, []string) {
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		err = testConfig.deployWithManifestAndVars(boshCommand, networksDeploymentName, stemcellVersion, releaseVersion,
+			filepath.Join(pwd, "assets", "networks-manifest.yml"),
+			map[string]interface{}{"Networks": networks})
+		Expect(err).NotTo(HaveOccurred())
+
+		instances := boshCommand.RunTable(fmt.Sprintf("-d %s instances --details", networksDeploymentName))
+		Expect(instances).To(HaveLen(1))
+		ips := strings.FieldsFunc(instances[0]["ips"], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\n'
+		})
+
+		var report networkReport
+		body := runErrandAndReadLog(networksDeploymentName, "check-network", "check-network/network.json", boshCommand)
+		Expect(json.Unmarshal(body, &report)).To(Succeed())
+
+		return report, ips
+	}
+
+	expectMetadataServerRules := func(report networkReport) {
+		expected := testConfig.expectations().Firewall.MetadataServerRules
+		enabled := map[string]bool{}
+		for _, rule := range report.MetadataServerRules {
+			if rule.Enabled {
+				enabled[rule.Name] = true
+			}
+			if rule.Enabled && rule.Direction == "Outbound" && rule.Action == "Allow" {
+				Expect(expected).To(ContainElement(rule.Name), fmt.Sprintf("unexpected firewall rule %s allows access to the metadata server", rule.Name))
+			}
+		}
+		for _, name := range expected {
+			Expect(enabled).To(HaveKey(name), fmt.Sprintf("firewall rule %s for the metadata server is missing or disabled", name))
+		}
+	}
+
+	BeforeEach(func() {
+		networksDeploymentName = fmt.Sprintf("windows-acceptance-test-networks-%d", getTimestampInMs())
+	})
+
+	AfterEach(func() {
+		if testConfig.SkipCleanup {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", networksDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("configures a static IP", func() {
+		if testConfig.StaticIP == "" {
+			Skip("Skipping static IP test - static_ip not set")
+		}
+
+		report, ips := deployNetworks([]map[string]interface{}{
+			{"name": testConfig.Network, "static_ips": []string{testConfig.StaticIP}},
+		})
+
+		Expect(ips).To(ConsistOf(testConfig.StaticIP))
+		Expect(report.Networks).To(HaveKeyWithValue(testConfig.Network, HaveField("IP", testConfig.StaticIP)))
+		staticInterface := report.interfaceWithIP(testConfig.StaticIP)
+		Expect(report.defaultInterface()).To(Equal(staticInterface))
+		Expect(report.dnsServers(staticInterface)).NotTo(BeEmpty())
+		expectMetadataServerRules(report)
+	})
+
+	It("configures two networks, with the default gateway and DNS on one of them", func() {
+		if testConfig.SecondNetwork == "" {
+			Skip("Skipping multiple networks test - second_network not set")
+		}
+
+		report, ips := deployNetworks([]map[string]interface{}{
+			{"name": testConfig.Network, "default": []string{"dns", "gateway"}},
+			{"name": testConfig.SecondNetwork},
+		})
+
+		Expect(ips).To(HaveLen(2))
+		interfaces := map[int]bool{}
+		for _, ip := range ips {
+			interfaces[report.interfaceWithIP(ip)] = true
+		}
+		Expect(interfaces).To(HaveLen(2), "each network should be configured on its own interface")
+
+		Expect(report.Networks).To(HaveLen(2))
+		Expect(report.Networks[testConfig.Network].Default).To(ConsistOf("dns", "gateway"))
+		Expect(report.Networks[testConfig.SecondNetwork].Default).To(BeEmpty())
+		Expect(ips).To(ConsistOf(report.Networks[testConfig.Network].IP, report.Networks[testConfig.SecondNetwork].IP))
+
+		defaultInterface := report.interfaceWithIP(report.Networks[testConfig.Network].IP)
+		Expect(report.defaultInterface()).To(Equal(defaultInterface), "the default route should use the network with the default gateway")
+		Expect(report.dnsServers(defaultInterface)).NotTo(BeEmpty())
+		expectMetadataServerRules(report)
+	})
+})
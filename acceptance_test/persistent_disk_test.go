@@ -0,0 +1,128 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// persistentDisk is written by the persistent-disk errand before it writes new data.
+type persistentDisk struct {
+	ComputerName  string   `json:"computer_name"`
+	Mounted       bool     `json:"mounted"`
+	Filesystem    string   `json:"filesystem"`
+	DiskSizeBytes int64    `json:"disk_size_bytes"`
+	Acls          []string `json:"acls"`
+	Data          string   `json:"data"`
+}
+
+var _ = Describe("Persistent disk", func() {
+	var (
+		diskDeploymentName string
+		diskManifestPath   string
+		opsFilesDir        string
+	)
+
+	deployDisk := func(data string, vars map[string]interface{}, opsFilePaths ...string) {
+		allVars := map[string]interface{}{
+			"PersistentDiskType": testConfig.PersistentDiskType,
+			"PersistentDiskData": data,
+		}
+		for k, v := range vars {
+			allVars[k] = v
+		}
+
+		err := testConfig.deployWithManifestAndVars(boshCommand, diskDeploymentName, stemcellVersion, releaseVersion, diskManifestPath, allVars, opsFilePaths...)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	readDisk := func() persistentDisk {
+		var d persistentDisk
+		body := runErrandAndReadLog(diskDeploymentName, "persistent-disk", "persistent-disk/disk.json", boshCommand)
+		Expect(json.Unmarshal(body, &d)).To(Succeed())
+		return d
+	}
+
+	diskCids := func() string {
+		instances := boshCommand.RunTable(fmt.Sprintf("-d %s instances --details", diskDeploymentName))
+		Expect(instances).To(HaveLen(1))
+		return instances[0]["disk_cids"]
+	}
+
+	BeforeEach(func() {
+		if testConfig.PersistentDiskType == "" {
+			Skip("Skipping persistent disk test - persistent_disk_type is required")
+		}
+
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		opsFilesDir = filepath.Join(pwd, "assets")
+		diskManifestPath = filepath.Join(opsFilesDir, "persistent-disk-manifest.yml")
+
+		diskDeploymentName = fmt.Sprintf("windows-acceptance-test-persistent-disk-%d", getTimestampInMs())
+	})
+
+	AfterEach(func() {
+		if testConfig.SkipCleanup || diskDeploymentName == "" {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", diskDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("mounts, resizes, migrates and detaches the persistent disk, keeping its data", func() {
+		data := fmt.Sprintf("persistent-disk-%d", getTimestampInMs())
+
+		By("Deploying with the persistent disk type and writing data")
+		deployDisk(data, nil)
+		originalCid := diskCids()
+		Expect(originalCid).NotTo(BeEmpty())
+
+		disk := readDisk()
+		Expect(disk.Mounted).To(BeTrue(), `the persistent disk should be mounted at C:\var\vcap\store`)
+		Expect(disk.Filesystem).To(Equal("NTFS"))
+
+		var allowed []string
+		for _, identity := range testConfig.expectations().Acls.Identities {
+			allowed = append(allowed, strings.ReplaceAll(identity, "%COMPUTERNAME%", disk.ComputerName))
+		}
+		Expect(disk.Acls).NotTo(BeEmpty())
+		for _, acl := range disk.Acls {
+			Expect(allowed).To(ContainElement(acl), `unexpected ACL on C:\var\vcap\store`)
+		}
+
+		By("Resizing the persistent disk")
+		const gib = 1024 * 1024 * 1024
+		resizedMB := ((disk.DiskSizeBytes+gib-1)/gib + 1) * 1024
+		deployDisk("", map[string]interface{}{"PersistentDiskSize": resizedMB}, filepath.Join(opsFilesDir, "persistent-disk-size.yml"))
+		resizedCid := diskCids()
+		Expect(resizedCid).NotTo(BeEmpty())
+		Expect(resizedCid).NotTo(Equal(originalCid), "resizing the persistent disk should have migrated its data to a new disk")
+
+		resized := readDisk()
+		Expect(resized.Mounted).To(BeTrue())
+		Expect(resized.DiskSizeBytes).To(BeNumerically(">", disk.DiskSizeBytes))
+		Expect(resized.Data).To(Equal(data), "data should survive resizing the persistent disk")
+
+		By("Migrating back to the persistent disk type")
+		deployDisk("", nil)
+		migratedCid := diskCids()
+		Expect(migratedCid).NotTo(BeEmpty())
+		Expect(migratedCid).NotTo(Equal(resizedCid), "changing the persistent disk type should have migrated its data to a new disk")
+
+		migrated := readDisk()
+		Expect(migrated.Mounted).To(BeTrue())
+		Expect(migrated.Data).To(Equal(data), "data should survive migrating the persistent disk")
+
+		By("Detaching the persistent disk")
+		deployDisk("", nil, filepath.Join(opsFilesDir, "persistent-disk-detach.yml"))
+		Expect(diskCids()).To(BeEmpty())
+		Expect(readDisk().Mounted).To(BeFalse())
+	})
+})
@@ -0,0 +1,167 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	scalingCanaries    = 1
+	scalingMaxInFlight = 2
+)
+
+// identity is written by the identity errand on each instance.
+type identity struct {
+	Index           int      `json:"index"`
+	Hostname        string   `json:"hostname"`
+	PasswordLastSet string   `json:"password_last_set"`
+	Updates         []update `json:"updates"`
+}
+
+// update records when the pre-start script of the identity job ran, in unix ms.
+type update struct {
+	Generation int   `json:"generation"`
+	Start      int64 `json:"start"`
+	End        int64 `json:"end"`
+}
+
+var _ = Describe("Scaling", func() {
+	var scalingDeploymentName string
+
+	deployScaling := func(instances, generation int) {
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		err = testConfig.deployWithManifestAndVars(boshCommand, scalingDeploymentName, stemcellVersion, releaseVersion,
+			filepath.Join(pwd, "assets", "scaling-manifest.yml"),
+			map[string]interface{}{
+				"Instances":   instances,
+				"Generation":  generation,
+				"Canaries":    scalingCanaries,
+				"MaxInFlight": scalingMaxInFlight,
+			})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	readIdentities := func() []identity {
+		var identities []identity
+		for _, body := range runErrandAndReadLogs(scalingDeploymentName, "identity", "identity/identity.json", boshCommand) {
+			var i identity
+			Expect(json.Unmarshal(body, &i)).To(Succeed())
+			identities = append(identities, i)
+		}
+		return identities
+	}
+
+	expectUnique := func(identities []identity) {
+		indexes := map[int]bool{}
+		hostnames := map[string]int{}
+		for _, i := range identities {
+			Expect(indexes).NotTo(HaveKey(i.Index), fmt.Sprintf("index %d is reported twice", i.Index))
+			indexes[i.Index] = true
+
+			Expect(hostnames).NotTo(HaveKey(i.Hostname), fmt.Sprintf("instances %d and %d share the hostname %s", hostnames[i.Hostname], i.Index, i.Hostname))
+			hostnames[i.Hostname] = i.Index
+		}
+	}
+
+	// expectPasswordSetSince asserts that the password of the built-in
+	// administrator was set on each VM, rather than inherited from the image.
+	// The passwords themselves cannot be compared, so this does not show that
+	// they differ between VMs.
+	expectPasswordSetSince := func(identities []identity, since time.Time) {
+		for _, i := range identities {
+			lastSet, err := time.Parse(time.RFC3339Nano, i.PasswordLastSet)
+			Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("instance %d reported an invalid password_last_set", i.Index))
+			Expect(lastSet).To(BeTemporally(">=", since), fmt.Sprintf("the password of instance %d was not set when its VM was created", i.Index))
+		}
+	}
+
+	BeforeEach(func() {
+		scalingDeploymentName = fmt.Sprintf("windows-acceptance-test-scaling-%d", getTimestampInMs())
+	})
+
+	AfterEach(func() {
+		if testConfig.SkipCleanup {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", scalingDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("scales up and down with unique hostnames and passwords set on each VM, and updates instances with canaries and max_in_flight", func() {
+		if testConfig.ScaleInstances < 2 {
+			Skip("Skipping scaling test - scale_instances must be at least 2")
+		}
+
+		// The clocks of the VMs may be a little behind that of the suite.
+		deployStarted := time.Now().Add(-time.Minute)
+
+		By("Deploying a single instance")
+		deployScaling(1, 1)
+		Expect(readIdentities()).To(HaveLen(1))
+
+		By(fmt.Sprintf("Scaling up to %d instances", testConfig.ScaleInstances))
+		deployScaling(testConfig.ScaleInstances, 1)
+		Expect(boshCommand.RunTable(fmt.Sprintf("-d %s instances", scalingDeploymentName))).To(HaveLen(testConfig.ScaleInstances))
+		identities := readIdentities()
+		Expect(identities).To(HaveLen(testConfig.ScaleInstances))
+		expectUnique(identities)
+		expectPasswordSetSince(identities, deployStarted)
+
+		By(fmt.Sprintf("Updating all instances with %d canary and max_in_flight %d", scalingCanaries, scalingMaxInFlight))
+		deployScaling(testConfig.ScaleInstances, 2)
+		var updates []update
+		for _, i := range readIdentities() {
+			var updated bool
+			for _, u := range i.Updates {
+				if u.Generation == 2 {
+					updates = append(updates, u)
+					updated = true
+				}
+			}
+			Expect(updated).To(BeTrue(), fmt.Sprintf("instance %d was not updated", i.Index))
+		}
+		expectCanariesThenMaxInFlight(updates, scalingCanaries, scalingMaxInFlight)
+
+		By("Scaling down to a single instance")
+		deployScaling(1, 2)
+		Expect(boshCommand.RunTable(fmt.Sprintf("-d %s instances", scalingDeploymentName))).To(HaveLen(1))
+		Expect(readIdentities()).To(HaveLen(1))
+	})
+})
+
+// expectCanariesThenMaxInFlight asserts that the first updates, the canaries,
+// finished before any other started, and that no more than maxInFlight of the
+// others ran at the same time.
+func expectCanariesThenMaxInFlight(updates []update, canaries, maxInFlight int) {
+	Expect(len(updates)).To(BeNumerically(">", canaries))
+
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Start < updates[j].Start
+	})
+
+	for _, canary := range updates[:canaries] {
+		for _, other := range updates[canaries:] {
+			Expect(canary.End).To(BeNumerically("<=", other.Start), "an instance was updated before the canaries finished")
+		}
+	}
+
+	for _, u := range updates[canaries:] {
+		inFlight := 0
+		for _, other := range updates[canaries:] {
+			if other.Start <= u.Start && u.Start < other.End {
+				inFlight++
+			}
+		}
+		Expect(inFlight).To(BeNumerically("<=", maxInFlight), "more instances were updated at once than max_in_flight")
+	}
+}
@@ -0,0 +1,174 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+)
+
+// sshUsersReport is written by the check-ssh errand.
+type sshUsersReport struct {
+	Users []string `json:"users"`
+	Files []string `json:"files"`
+}
+
+// expectNoSSHUsers checks that every bosh ssh session to a deployment removed
+// its user, and the files of its profile.
+func expectNoSSHUsers(deployment string) {
+	// The errand fails when users are left, after reporting them.
+	var report sshUsersReport
+	errandErr := runErrandAndReadReport(deployment, "check-ssh", "check-ssh/ssh-users.json", &report, boshCommand)
+	Expect(report.Users).To(BeEmpty(), "bosh ssh users were not removed")
+	Expect(report.Files).To(BeEmpty(), "the profiles of bosh ssh users were not removed")
+	Expect(errandErr).NotTo(HaveOccurred())
+}
+
+var _ = Describe("SSH", func() {
+	const instance = "check-multiple/0"
+
+	BeforeEach(func() {
+		if testConfig.SSHDisabledByDefault {
+			Skip("Skipping SSH test - ssh is disabled by default")
+		}
+	})
+
+	AfterEach(func() {
+		if testConfig.SSHDisabledByDefault {
+			return
+		}
+		expectNoSSHUsers(deploymentName)
+	})
+
+	It("runs commands and returns their output and exit code", func() {
+		results, err := harness.SSH(boshCommand.Bosh, deploymentName, instance, "echo bwats-ssh")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(ConsistOf(And(HaveField("Stdout", "bwats-ssh"), HaveField("ExitCode", 0))))
+
+		results, err = harness.SSH(boshCommand.Bosh, deploymentName, instance, "exit 7")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(ConsistOf(HaveField("ExitCode", 7)))
+	})
+
+	It("copies files to and from an instance", func() {
+		localDir := GinkgoT().TempDir()
+		content := fmt.Sprintf("bwats-scp-%d", getTimestampInMs())
+		uploaded := filepath.Join(localDir, "uploaded.txt")
+		Expect(os.WriteFile(uploaded, []byte(content), 0644)).To(Succeed())
+
+		// bosh scp splits the instance from the path on the first colon only.
+		remote := fmt.Sprintf("C:/Windows/Temp/%s.txt", content)
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s scp %s %s:%s", deploymentName, uploaded, instance, remote))).To(Succeed())
+		defer func() {
+			_, err := harness.SSH(boshCommand.Bosh, deploymentName, instance, fmt.Sprintf(`del C:\Windows\Temp\%s.txt`, content))
+			Expect(err).NotTo(HaveOccurred())
+		}()
+
+		results, err := harness.SSH(boshCommand.Bosh, deploymentName, instance, fmt.Sprintf(`type C:\Windows\Temp\%s.txt`, content))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(ConsistOf(And(HaveField("Stdout", content), HaveField("ExitCode", 0))))
+
+		downloaded := filepath.Join(localDir, "downloaded.txt")
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s scp %s:%s %s", deploymentName, instance, remote, downloaded))).To(Succeed())
+		Expect(os.ReadFile(downloaded)).To(Equal([]byte(content)))
+	})
+
+	It("forwards a local port to the instance", func() {
+		localPort := freeLocalPort()
+		tunnel := boshCommand.Start(fmt.Sprintf("-d %s ssh %s --opts=-N --opts=-L --opts=%d:localhost:22",
+			deploymentName, instance, localPort))
+		defer stopSession(tunnel)
+
+		// The tunnel reaches sshd on the instance, which greets with its version.
+		var banner string
+		Eventually(func(g Gomega) {
+			if tunnel.ExitCode() != -1 {
+				StopTrying("bosh ssh exited").Now()
+			}
+
+			conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", localPort), 10*time.Second)
+			g.Expect(err).NotTo(HaveOccurred())
+			defer conn.Close() //nolint:errcheck
+
+			g.Expect(conn.SetReadDeadline(time.Now().Add(10 * time.Second))).To(Succeed())
+			banner, err = bufio.NewReader(conn).ReadString('\n')
+			g.Expect(err).NotTo(HaveOccurred())
+		}, 3*time.Minute, 10*time.Second).Should(Succeed())
+
+		Expect(banner).To(HavePrefix("SSH-2.0-OpenSSH_for_Windows"))
+	})
+
+	It("runs concurrent sessions", func() {
+		const sessions = 3
+
+		var wg sync.WaitGroup
+		results := make([][]harness.SSHResult, sessions)
+		errs := make([]error, sessions)
+		for i := 0; i < sessions; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				results[i], errs[i] = harness.SSH(boshCommand.Bosh, deploymentName, instance, fmt.Sprintf("echo bwats-ssh-%d", i))
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < sessions; i++ {
+			Expect(errs[i]).NotTo(HaveOccurred(), fmt.Sprintf("session %d", i))
+			Expect(results[i]).To(ConsistOf(And(HaveField("Stdout", fmt.Sprintf("bwats-ssh-%d", i)), HaveField("ExitCode", 0))))
+		}
+	})
+})
+
+var _ = Describe("SSH disabled by default", Ordered, func() {
+	var sshDeploymentName string
+
+	BeforeAll(func() {
+		if !testConfig.SSHDisabledByDefault {
+			Skip("Skipping SSH disabled by default test - ssh is enabled by default")
+		}
+
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		sshDeploymentName = fmt.Sprintf("windows-acceptance-test-ssh-%d", getTimestampInMs())
+		err = testConfig.deployWithManifest(boshCommand, sshDeploymentName, stemcellVersion, releaseVersion,
+			filepath.Join(pwd, "assets", "ssh-manifest.yml"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterAll(func() {
+		if testConfig.SkipCleanup || sshDeploymentName == "" {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", sshDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("refuses bosh ssh", func() {
+		_, err := harness.SSH(boshCommand.Bosh, sshDeploymentName, "ssh/0", "echo bwats-ssh")
+		Expect(err).To(HaveOccurred())
+
+		expectNoSSHUsers(sshDeploymentName)
+	})
+
+	It("allows bosh ssh once enabled by an errand", func() {
+		Expect(boshCommand.RunErrand("enable-ssh", sshDeploymentName)).To(Succeed())
+
+		results, err := harness.SSH(boshCommand.Bosh, sshDeploymentName, "ssh/0", "echo bwats-ssh")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(ConsistOf(And(HaveField("Stdout", "bwats-ssh"), HaveField("ExitCode", 0))))
+
+		expectNoSSHUsers(sshDeploymentName)
+	})
+})
@@ -0,0 +1,154 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// supervisedProcesses is written by the supervision-check errand.
+type supervisedProcesses struct {
+	Processes map[string][]supervisedProcess `json:"processes"`
+	Children  []supervisedProcess            `json:"children"`
+}
+
+type supervisedProcess struct {
+	Parent  int  `json:"parent"`
+	PID     int  `json:"pid"`
+	Running bool `json:"running"`
+}
+
+var supervisionProcessNames = []string{"crasher", "exiter", "stubborn", "parent"}
+
+// wrapperStopTimeout is how long job-service-wrapper waits for a process to
+// exit after Ctrl+C before killing it, its default stop timeout.
+const wrapperStopTimeout = 15 * time.Second
+
+var _ = Describe("Job supervision", func() {
+	var supervisionDeploymentName string
+
+	readProcesses := func() supervisedProcesses {
+		var p supervisedProcesses
+		body := runErrandAndReadLog(supervisionDeploymentName, "supervision-check", "supervision-check/processes.json", boshCommand)
+		Expect(json.Unmarshal(body, &p)).To(Succeed())
+		return p
+	}
+
+	processStates := func() map[string]string {
+		states := map[string]string{}
+		for _, row := range boshCommand.RunTable(fmt.Sprintf("-d %s instances --ps", supervisionDeploymentName)) {
+			if row["process"] != "" {
+				states[row["process"]] = row["process_state"]
+			}
+		}
+		return states
+	}
+
+	// expectOnlyLatestRunning asserts that processes from before the last
+	// start, and the children of earlier parents, are no longer running.
+	expectOnlyLatestRunning := func(p supervisedProcesses) {
+		for _, name := range []string{"stubborn", "parent"} {
+			pids := p.Processes[name]
+			Expect(pids).NotTo(BeEmpty(), fmt.Sprintf("%s never started", name))
+			for _, process := range pids[:len(pids)-1] {
+				Expect(process.Running).To(BeFalse(), fmt.Sprintf("%s with pid %d survived being stopped", name, process.PID))
+			}
+			Expect(pids[len(pids)-1].Running).To(BeTrue(), fmt.Sprintf("%s is not running", name))
+		}
+
+		parents := p.Processes["parent"]
+		latestParent := parents[len(parents)-1].PID
+		for _, child := range p.Children {
+			if child.Parent != latestParent {
+				Expect(child.Running).To(BeFalse(), fmt.Sprintf("child %d outlived its parent %d", child.PID, child.Parent))
+			}
+		}
+	}
+
+	BeforeEach(func() {
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+
+		supervisionDeploymentName = fmt.Sprintf("windows-acceptance-test-supervision-%d", getTimestampInMs())
+
+		err = testConfig.deployWithManifest(boshCommand, supervisionDeploymentName, stemcellVersion, releaseVersion,
+			filepath.Join(pwd, "assets", "supervision-manifest.yml"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if testConfig.SkipCleanup {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", supervisionDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("restarts processes that crash or exit non-zero", func() {
+		Expect(processStates()).To(HaveKey("crasher"))
+		Expect(processStates()).To(HaveKey("exiter"))
+
+		// The processes fail every supervision.fail_after_seconds (120s in the manifest).
+		Eventually(func(g Gomega) {
+			p := readProcesses()
+			for _, name := range []string{"crasher", "exiter"} {
+				g.Expect(len(p.Processes[name])).To(BeNumerically(">=", 2), fmt.Sprintf("%s was not restarted", name))
+
+				running := 0
+				for _, process := range p.Processes[name] {
+					if process.Running {
+						running++
+					}
+				}
+				g.Expect(running).To(BeNumerically("<=", 1), fmt.Sprintf("more than one %s is running", name))
+			}
+		}, 10*time.Minute, 30*time.Second).Should(Succeed())
+	})
+
+	It("stops processes that ignore stop signals, along with their children", func() {
+		initial := readProcesses()
+		Expect(initial.Children).NotTo(BeEmpty())
+
+		By("Stopping")
+		start := time.Now()
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s stop supervision/0", supervisionDeploymentName))).To(Succeed())
+		GinkgoWriter.Printf("Stopping took %s\n", time.Since(start))
+		Expect(time.Since(start)).To(BeNumerically(">=", wrapperStopTimeout), "stubborn should have been given the stop timeout to exit")
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Minute), "stubborn should have been killed after the stop timeout")
+
+		for _, name := range supervisionProcessNames {
+			Expect(processStates()).NotTo(HaveKeyWithValue(name, "running"))
+		}
+
+		By("Starting")
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s start supervision/0", supervisionDeploymentName))).To(Succeed())
+		expectOnlyLatestRunning(readProcesses())
+
+		By("Restarting")
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s restart supervision/0", supervisionDeploymentName))).To(Succeed())
+		restarted := readProcesses()
+		Expect(restarted.Processes["parent"]).To(HaveLen(len(initial.Processes["parent"]) + 2))
+		expectOnlyLatestRunning(restarted)
+	})
+
+	It("writes the output of processes to the job-service-wrapper logs", func() {
+		tempDir, err := os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempDir) //nolint:errcheck
+
+		tarball := downloadLogsTarball(supervisionDeploymentName, "supervision", 0, tempDir, boshCommand)
+		for _, name := range supervisionProcessNames {
+			stdout := readFromTarball(tarball, fmt.Sprintf("supervision/%s/job-service-wrapper.out.log", name))
+			Expect(string(stdout)).To(ContainSubstring(fmt.Sprintf("%s started with pid", name)))
+
+			stderr := readFromTarball(tarball, fmt.Sprintf("supervision/%s/job-service-wrapper.err.log", name))
+			Expect(string(stderr)).To(ContainSubstring(fmt.Sprintf("%s writes to stderr", name)))
+		}
+	})
+})
@@ -0,0 +1,51 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// updatesReport is written by the check-updates errand.
+type updatesReport struct {
+	OfflineCatalog bool            `json:"offline_catalog"`
+	Missing        []missingUpdate `json:"missing"`
+}
+
+type missingUpdate struct {
+	KB             string `json:"kb"`
+	Title          string `json:"title"`
+	Classification string `json:"classification"`
+	Severity       string `json:"severity"`
+}
+
+func (u missingUpdate) String() string {
+	s := fmt.Sprintf("%s %s (%s", u.KB, u.Title, u.Classification)
+	if u.Severity != "" {
+		s += ", " + u.Severity
+	}
+	return s + ")"
+}
+
+var _ = Describe("Windows updates", func() {
+	It("is fully updated", func() { // 860s
+		if testConfig.SkipMSUpdateTest {
+			Skip("Skipping check-updates test - SkipMSUpdateTest set to true")
+		}
+
+		// The errand fails when updates are missing, after reporting them.
+		var report updatesReport
+		errandErr := runErrandAndReadReport(deploymentName, "check-updates", "check-updates/updates.json", &report, boshCommand)
+		Expect(report.OfflineCatalog).To(Equal(testConfig.Updates.OfflineCatalogPath != ""))
+
+		var missing []string
+		for _, update := range report.Missing {
+			AddReportEntry("missing update "+update.KB, update.String())
+			missing = append(missing, update.String())
+		}
+		Expect(missing).To(BeEmpty(), "Updates are not installed:\n"+strings.Join(missing, "\n"))
+		Expect(errandErr).NotTo(HaveOccurred())
+	})
+})
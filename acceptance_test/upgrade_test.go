@@ -0,0 +1,104 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// markers is written by the disk-marker errand before it writes new markers.
+type markers struct {
+	Persistent      string `json:"persistent"`
+	Ephemeral       string `json:"ephemeral"`
+	StemcellVersion string `json:"stemcell_version"`
+}
+
+var _ = Describe("Upgrading from a baseline stemcell", func() {
+	var (
+		upgradeDeploymentName   string
+		baselineStemcellVersion string
+		upgradeManifestPath     string
+	)
+
+	deployUpgrade := func(stemcellVersion, markerValue string) {
+		err := testConfig.deployWithManifestAndVars(boshCommand, upgradeDeploymentName, stemcellVersion, releaseVersion, upgradeManifestPath,
+			map[string]interface{}{
+				"PersistentDiskType": testConfig.PersistentDiskType,
+				"MarkerValue":        markerValue,
+			})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	readMarkers := func() markers {
+		var m markers
+		body := runErrandAndReadLog(upgradeDeploymentName, "disk-marker", "disk-marker/markers.json", boshCommand)
+		Expect(json.Unmarshal(body, &m)).To(Succeed())
+		return m
+	}
+
+	BeforeEach(func() {
+		if testConfig.BaselineStemcellPath == "" || testConfig.PersistentDiskType == "" {
+			Skip("Skipping upgrade test - baseline_stemcell_path and persistent_disk_type are required")
+		}
+
+		pwd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		upgradeManifestPath = filepath.Join(pwd, "assets", "upgrade-manifest.yml")
+
+		matches, err := filepath.Glob(testConfig.BaselineStemcellPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matches).To(HaveLen(1))
+
+		baselineStemcell, err := fetchStemcellInfo(matches[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(baselineStemcell.Name).To(Equal(stemcellName), "the baseline stemcell must be for the same IaaS and OS")
+		Expect(baselineStemcell.Version).NotTo(Equal(stemcellVersion), "the baseline stemcell must be a different version")
+		baselineStemcellVersion = baselineStemcell.Version
+
+		uploadStemcellFile(testConfig.BaselineStemcellPath, boshCommand)
+
+		upgradeDeploymentName = fmt.Sprintf("windows-acceptance-test-upgrade-%d", getTimestampInMs())
+	})
+
+	AfterEach(func() {
+		if testConfig.SkipCleanup || upgradeDeploymentName == "" {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", upgradeDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+		err = boshCommand.Run(fmt.Sprintf("delete-stemcell %s/%s", stemcellName, baselineStemcellVersion))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("keeps persistent data, restarts jobs and recreates the ephemeral disk when redeployed on the stemcell under test", func() {
+		marker := fmt.Sprintf("upgrade-%d", getTimestampInMs())
+
+		By(fmt.Sprintf("Deploying the baseline stemcell %s and writing markers to the disks", baselineStemcellVersion))
+		deployUpgrade(baselineStemcellVersion, marker)
+		before := readMarkers()
+		Expect(before.StemcellVersion).To(Equal(baselineStemcellVersion))
+
+		By(fmt.Sprintf("Redeploying on the stemcell under test %s", stemcellVersion))
+		deployUpgrade(stemcellVersion, "")
+
+		vms := boshCommand.RunTable(fmt.Sprintf("-d %s vms", upgradeDeploymentName))
+		Expect(vms).To(HaveLen(1))
+		Expect(vms[0]["stemcell"]).To(ContainSubstring(stemcellVersion))
+
+		instances := boshCommand.RunTable(fmt.Sprintf("-d %s instances --details", upgradeDeploymentName))
+		Expect(instances).To(HaveLen(1))
+		Expect(instances[0]["process_state"]).To(Equal("running"), "the agent should report the jobs as running")
+		Expect(instances[0]["disk_cids"]).NotTo(BeEmpty())
+
+		after := readMarkers()
+		Expect(after.StemcellVersion).To(Equal(stemcellVersion))
+		Expect(after.Persistent).To(Equal(marker), "data on the persistent disk should survive a stemcell upgrade")
+		// Changing the stemcell recreates the VM, so the ephemeral disk starts out empty.
+		Expect(after.Ephemeral).To(BeEmpty(), "data on the ephemeral disk should not survive a stemcell upgrade")
+	})
+})
@@ -0,0 +1,90 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/checks"
+)
+
+// lightChecks are the check-system checks re-run after a VM operation. They
+// cover what the agent sets up again when it bootstraps a new VM.
+var lightChecks = []string{"Verify-AgentBehavior", "Verify-RandomPassword"}
+
+var _ = Describe("VM lifecycle operations", Ordered, func() {
+	var vmDeploymentName string
+
+	vm := func() map[string]string {
+		vms := boshCommand.RunTable(fmt.Sprintf("-d %s vms", vmDeploymentName))
+		Expect(vms).To(HaveLen(1))
+		return vms[0]
+	}
+
+	expectHealthy := func() {
+		current := vm()
+		Expect(current["process_state"]).To(Equal("running"))
+
+		stdout, err := boshCommand.RunErrandStdOut("check-system", vmDeploymentName)
+		report := checks.ParseReport(stdout)
+		GinkgoWriter.Printf("%s", report.String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.WithStatus(checks.Passed)).To(ConsistOf(lightChecks))
+
+		Expect(boshCommand.RunErrand("ephemeral-disk", vmDeploymentName)).To(Succeed())
+	}
+
+	BeforeAll(func() {
+		vmDeploymentName = fmt.Sprintf("windows-acceptance-test-vm-lifecycle-%d", getTimestampInMs())
+
+		// Deploy check-multiple on its own, so that the main deployment is
+		// left untouched, running only the light checks.
+		config := *testConfig
+		config.Checks.Focus = lightChecks
+		config.Checks.Skip = nil
+		Expect(config.deploy(boshCommand, vmDeploymentName, stemcellVersion, releaseVersion)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		if testConfig.SkipCleanup {
+			return
+		}
+
+		err := boshCommand.Run(fmt.Sprintf("-d %s delete-deployment --force", vmDeploymentName))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("recreates the VM", func() {
+		before := vm()["vm_cid"]
+
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s recreate check-multiple/0", vmDeploymentName))).To(Succeed())
+
+		Expect(vm()["vm_cid"]).NotTo(Equal(before))
+		expectHealthy()
+	})
+
+	It("deletes the VM on a hard stop and creates a new one on start", func() {
+		before := vm()["vm_cid"]
+
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s stop check-multiple/0 --hard", vmDeploymentName))).To(Succeed())
+		instances := boshCommand.RunTable(fmt.Sprintf("-d %s instances --details", vmDeploymentName))
+		Expect(instances).To(HaveLen(1))
+		Expect(instances[0]["vm_cid"]).To(BeEmpty())
+
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s start check-multiple/0", vmDeploymentName))).To(Succeed())
+
+		Expect(vm()["vm_cid"]).NotTo(Equal(before))
+		expectHealthy()
+	})
+
+	It("resurrects a deleted VM with cloud check", func() {
+		before := vm()["vm_cid"]
+
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s delete-vm %s", vmDeploymentName, before))).To(Succeed())
+		Expect(boshCommand.Run(fmt.Sprintf("-d %s cck --auto", vmDeploymentName))).To(Succeed())
+
+		Expect(vm()["vm_cid"]).NotTo(Equal(before))
+		expectHealthy()
+	})
+})
@@ -0,0 +1,35 @@
+package windows_stemcell_acceptance_test
+
+import (
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/harness"
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/wucerts"
+)
+
+var _ = Describe("Windows Update root certificates", func() {
+	It("has all certificate authority certs that are present on the Windows Update Server", func() {
+		tempDir, err := os.MkdirTemp("", "")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempDir) //nolint:errcheck
+
+		tarballs, err := harness.RunErrandAndDownloadLogs(boshCommand.Bosh, deploymentName, "check-wu-certs", tempDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tarballs).To(HaveLen(1))
+
+		expected, err := wucerts.Parse(readFromTarball(tarballs[0], "check-wu-certs/wucerts.sst"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expected).NotTo(BeEmpty())
+
+		system, err := wucerts.ParseSystemCerts(readFromTarball(tarballs[0], "check-wu-certs/system-certs.json"))
+		Expect(err).NotTo(HaveOccurred())
+
+		report := wucerts.Compare(expected, system, time.Now())
+		AddReportEntry("check-wu-certs", report.String())
+		Expect(report.Missing).To(BeEmpty(), report.String())
+	})
+})
@@ -0,0 +1,131 @@
+package wucerts
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/inventory"
+)
+
+// rootStores are the stores of trusted root certificates, which the
+// certificates of the Windows Update server are expected in.
+var rootStores = []string{`LocalMachine\Root`, `LocalMachine\AuthRoot`}
+
+// Cert is a certificate of the report.
+type Cert struct {
+	Thumbprint string
+	Subject    string
+	NotAfter   time.Time
+	// Store is that of a system certificate, empty for one of the SST.
+	Store string
+}
+
+// Report compares the certificates of the Windows Update server with those of
+// the system stores. Only Missing certificates are failures: expired ones are
+// not expected to be installed, and Extra ones may be added by the stemcell.
+type Report struct {
+	Expected int
+	Missing  []Cert
+	Expired  []Cert
+	// Extra are the certificates of the root stores that are not in the SST.
+	Extra []Cert
+}
+
+// ParseSystemCerts parses the system-certs.json written by the check-wu-certs
+// errand: the certificates of every system store.
+func ParseSystemCerts(body []byte) ([]inventory.Certificate, error) {
+	var certs []inventory.Certificate
+	if err := json.Unmarshal(body, &certs); err != nil {
+		return nil, fmt.Errorf("unable to parse the system certificates: %s", err)
+	}
+	return certs, nil
+}
+
+// Compare reports the certificates of the SST that are in none of the system
+// stores, and the certificates of the root stores that are not in the SST.
+func Compare(expected []*x509.Certificate, system []inventory.Certificate, now time.Time) Report {
+	report := Report{Expected: len(expected)}
+
+	installed := map[string]bool{}
+	for _, cert := range system {
+		installed[strings.ToUpper(cert.Thumbprint)] = true
+	}
+
+	fromWU := map[string]bool{}
+	for _, cert := range expected {
+		thumbprint := Thumbprint(cert)
+		fromWU[thumbprint] = true
+		if installed[thumbprint] {
+			continue
+		}
+
+		c := Cert{Thumbprint: thumbprint, Subject: cert.Subject.String(), NotAfter: cert.NotAfter}
+		if now.After(cert.NotAfter) {
+			report.Expired = append(report.Expired, c)
+		} else {
+			report.Missing = append(report.Missing, c)
+		}
+	}
+
+	for _, cert := range system {
+		if fromWU[strings.ToUpper(cert.Thumbprint)] || !isRootStore(cert.Store) {
+			continue
+		}
+		notAfter, _ := time.Parse(time.RFC3339, cert.NotAfter)
+		report.Extra = append(report.Extra, Cert{
+			Thumbprint: strings.ToUpper(cert.Thumbprint),
+			Subject:    cert.Subject,
+			NotAfter:   notAfter,
+			Store:      cert.Store,
+		})
+	}
+
+	for _, certs := range [][]Cert{report.Missing, report.Expired, report.Extra} {
+		sort.Slice(certs, func(i, j int) bool {
+			if certs[i].Subject != certs[j].Subject {
+				return certs[i].Subject < certs[j].Subject
+			}
+			return certs[i].Thumbprint < certs[j].Thumbprint
+		})
+	}
+
+	return report
+}
+
+func isRootStore(store string) bool {
+	for _, root := range rootStores {
+		if strings.EqualFold(store, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d certificates on the Windows Update server, %d missing\n", r.Expected, len(r.Missing))
+
+	section := func(name string, certs []Cert) {
+		if len(certs) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s (%d)\n", name, len(certs))
+		for _, c := range certs {
+			fmt.Fprintf(&b, "  %s %s (expires %s", c.Thumbprint, c.Subject, c.NotAfter.Format("2006-01-02"))
+			if c.Store != "" {
+				fmt.Fprintf(&b, ", in %s", c.Store)
+			}
+			b.WriteString(")\n")
+		}
+	}
+
+	section("Missing", r.Missing)
+	section("Expired, not installed", r.Expired)
+	section("In the root stores, not on the Windows Update server", r.Extra)
+
+	return b.String()
+}
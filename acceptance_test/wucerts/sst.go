@@ -0,0 +1,124 @@
+// Package wucerts compares the root certificates of the Windows Update server,
+// as written to an SST file by `certutil -generateSSTFromWU`, with the
+// certificates in the system stores of a stemcell.
+package wucerts
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// serializedStoreMagic is 'CERT', the magic of a serialized certificate
+	// store, after its version DWORD of 0.
+	serializedStoreMagic = 0x54524543
+
+	// certElement is the property ID of an element holding a DER encoded
+	// certificate. The other elements are properties of the certificate, e.g.
+	// its friendly name, or CRLs and CTLs.
+	certElement = 32
+
+	elementHeaderSize = 12
+)
+
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// Parse returns the certificates of an SST file, which is either a serialized
+// certificate store or a PKCS#7 SignedData.
+func Parse(sst []byte) ([]*x509.Certificate, error) {
+	if len(sst) >= 8 && binary.LittleEndian.Uint32(sst[0:4]) == 0 && binary.LittleEndian.Uint32(sst[4:8]) == serializedStoreMagic {
+		return parseSerializedStore(sst[8:])
+	}
+	return parsePKCS7(sst)
+}
+
+// parseSerializedStore parses the elements of a serialized store, after its
+// header. Each element is {property ID, encoding type, length, data} with
+// little-endian DWORDs, and the store ends with an element of zeros.
+func parseSerializedStore(elements []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for offset := 0; ; {
+		if len(elements)-offset < elementHeaderSize {
+			return nil, fmt.Errorf("serialized store: truncated element header at offset %d", offset+8)
+		}
+
+		propID := binary.LittleEndian.Uint32(elements[offset:])
+		length := binary.LittleEndian.Uint32(elements[offset+8:])
+		offset += elementHeaderSize
+		if propID == 0 && length == 0 {
+			return certs, nil
+		}
+
+		if uint64(length) > uint64(len(elements)-offset) {
+			return nil, fmt.Errorf("serialized store: element %d of %d bytes at offset %d is truncated", propID, length, offset+8)
+		}
+		data := elements[offset : offset+int(length)]
+		offset += int(length)
+
+		if propID != certElement {
+			continue
+		}
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, fmt.Errorf("serialized store: certificate %d: %s", len(certs)+1, err)
+		}
+		certs = append(certs, cert)
+	}
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// parsePKCS7 returns the certificates of a DER encoded PKCS#7 SignedData.
+func parsePKCS7(der []byte) ([]*x509.Certificate, error) {
+	var info contentInfo
+	rest, err := asn1.Unmarshal(der, &info)
+	if err != nil {
+		return nil, fmt.Errorf("neither a serialized store nor PKCS#7: %s", err)
+	}
+	if len(bytes.TrimRight(rest, "\x00")) != 0 {
+		return nil, errors.New("PKCS#7: trailing data")
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("PKCS#7: content type %s is not SignedData", info.ContentType)
+	}
+
+	var data signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &data); err != nil {
+		return nil, fmt.Errorf("PKCS#7: %s", err)
+	}
+	if len(data.Certificates.Bytes) == 0 {
+		return nil, nil
+	}
+
+	certs, err := x509.ParseCertificates(data.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#7: %s", err)
+	}
+	return certs, nil
+}
+
+// Thumbprint is the SHA-1 of a certificate in upper case hex, as Windows
+// shows it.
+func Thumbprint(cert *x509.Certificate) string {
+	sum := sha1.Sum(cert.Raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
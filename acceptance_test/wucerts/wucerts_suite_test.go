@@ -0,0 +1,13 @@
+package wucerts_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestWucerts(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Wucerts Suite")
+}
@@ -0,0 +1,99 @@
+package wucerts_test
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry/bosh-windows-acceptance-tests/acceptance_test/wucerts"
+)
+
+const (
+	installedRoot = "E0EAAB8FB874CCD750F6F9AAA3C4BAD968C519D8"
+	missingRoot   = "84A33059313B9F6DA73661929E21E8184832AEE4"
+	expiredRoot   = "E47B2CCB92DEA85FF720BDAF92885A6E78992C13"
+)
+
+func readTestdata(name string) []byte {
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	Expect(err).NotTo(HaveOccurred())
+	return body
+}
+
+func thumbprints(certs []*x509.Certificate) []string {
+	var result []string
+	for _, cert := range certs {
+		result = append(result, wucerts.Thumbprint(cert))
+	}
+	return result
+}
+
+var _ = Describe("Parse", func() {
+	DescribeTable("returns the certificates of an SST",
+		func(name string) {
+			certs, err := wucerts.Parse(readTestdata(name))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(thumbprints(certs)).To(Equal([]string{installedRoot, missingRoot, expiredRoot}))
+			Expect(certs[0].Subject.CommonName).To(Equal("BWATS Installed Root"))
+		},
+		Entry("serialized certificate store", "wucerts-serialized.sst"),
+		Entry("PKCS#7", "wucerts-pkcs7.sst"),
+	)
+
+	It("fails on a truncated serialized store", func() {
+		sst := readTestdata("wucerts-serialized.sst")
+		_, err := wucerts.Parse(sst[:len(sst)-100])
+		Expect(err).To(MatchError(ContainSubstring("truncated")))
+	})
+
+	It("fails on something else", func() {
+		_, err := wucerts.Parse([]byte("<html>Service Unavailable</html>"))
+		Expect(err).To(MatchError(ContainSubstring("neither a serialized store nor PKCS#7")))
+	})
+})
+
+var _ = Describe("Compare", func() {
+	var report wucerts.Report
+
+	BeforeEach(func() {
+		expected, err := wucerts.Parse(readTestdata("wucerts-serialized.sst"))
+		Expect(err).NotTo(HaveOccurred())
+		system, err := wucerts.ParseSystemCerts(readTestdata("system-certs.json"))
+		Expect(err).NotTo(HaveOccurred())
+
+		report = wucerts.Compare(expected, system, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	})
+
+	It("reports the certificates of the SST which are not installed, expired or not", func() {
+		Expect(report.Expected).To(Equal(3))
+		Expect(report.Missing).To(ConsistOf(HaveField("Thumbprint", missingRoot)))
+		Expect(report.Missing[0].Subject).To(Equal("CN=BWATS Missing Root,O=BWATS Test"))
+		Expect(report.Expired).To(ConsistOf(HaveField("Thumbprint", expiredRoot)))
+	})
+
+	It("reports the certificates of the root stores which are not in the SST", func() {
+		Expect(report.Extra).To(ConsistOf(And(
+			HaveField("Thumbprint", "0563B8630D62D75ABBC8AB1E4BDFB5A899B24D43"),
+			HaveField("Store", `LocalMachine\Root`),
+		)))
+		Expect(report.Extra[0].NotAfter).To(Equal(time.Date(2031, 11, 10, 0, 0, 0, 0, time.UTC)))
+	})
+
+	It("describes the certificates by thumbprint and subject", func() {
+		Expect(report.String()).To(Equal(`3 certificates on the Windows Update server, 1 missing
+
+Missing (1)
+  84A33059313B9F6DA73661929E21E8184832AEE4 CN=BWATS Missing Root,O=BWATS Test (expires 2099-01-01)
+
+Expired, not installed (1)
+  E47B2CCB92DEA85FF720BDAF92885A6E78992C13 CN=BWATS Expired Root,O=BWATS Test (expires 2020-01-01)
+
+In the root stores, not on the Windows Update server (1)
+  0563B8630D62D75ABBC8AB1E4BDFB5A899B24D43 CN=DigiCert Assured ID Root CA, OU=www.digicert.com, O=DigiCert Inc, C=US (expires 2031-11-10, in LocalMachine\Root)
+`))
+	})
+})